@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-web-wa/pkg/config"
+	"go-web-wa/pkg/correlation"
+	"go-web-wa/pkg/storage"
+	"go-web-wa/pkg/whatsapp"
+)
+
+const (
+	// maxAPIRequestBytes bounds the request body of API calls
+	maxAPIRequestBytes = 64 * 1024
+	// maxLookupNumbers bounds how many numbers one POST /lookup may check
+	maxLookupNumbers = 100
+)
+
+// fetchRequest is the body of POST /fetch
+type fetchRequest struct {
+	Number string `json:"number"`
+}
+
+// fetchResponse is returned by POST /fetch. The image is linked through
+// ImageURL when it was stored with a public URL and inlined as base64 otherwise.
+type fetchResponse struct {
+	Number        string    `json:"number"`
+	Name          string    `json:"name,omitempty"`
+	PictureID     string    `json:"picture_id,omitempty"`
+	Type          string    `json:"type,omitempty"`
+	Hash          string    `json:"hash"`
+	Size          int       `json:"size"`
+	FetchedAt     time.Time `json:"fetched_at"`
+	ImageURL      string    `json:"image_url,omitempty"`
+	Image         []byte    `json:"image,omitempty"`
+	CorrelationID string    `json:"correlation_id"`
+}
+
+// runRequest is the body of POST /run; no numbers means the configured targets
+type runRequest struct {
+	Numbers []string `json:"numbers"`
+}
+
+// runResponse is returned by POST /run with one entry per target, in order
+type runResponse struct {
+	Results  []runResult `json:"results"`
+	Duration string      `json:"duration"`
+}
+
+// runResult is the outcome of one target of a run
+type runResult struct {
+	Number        string `json:"number"`
+	Changed       bool   `json:"changed,omitempty"`
+	Skipped       bool   `json:"skipped,omitempty"`
+	Unchanged     bool   `json:"unchanged,omitempty"`
+	Suppressed    bool   `json:"suppressed,omitempty"`
+	Removed       bool   `json:"removed,omitempty"`
+	Bytes         int    `json:"bytes,omitempty"`
+	Error         string `json:"error,omitempty"`
+	CorrelationID string `json:"correlation_id"`
+}
+
+// lookupRequest is the body of POST /lookup
+type lookupRequest struct {
+	Numbers []string `json:"numbers"`
+}
+
+// lookupResponse is returned by POST /lookup with one entry per requested number, in order
+type lookupResponse struct {
+	Results       []lookupResult `json:"results"`
+	CorrelationID string         `json:"correlation_id"`
+}
+
+// lookupResult describes one number. Error is set when the number couldn't be
+// checked; AvatarError when it is on WhatsApp but the avatar couldn't be fetched.
+type lookupResult struct {
+	Number      string `json:"number"`
+	OnWhatsApp  bool   `json:"on_whatsapp"`
+	JID         string `json:"jid,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Business    string `json:"business,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	AvatarError string `json:"avatar_error,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// apiError is the body of every non-200 API response
+type apiError struct {
+	Error         string `json:"error"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// serveAPI answers on-demand fetches on listener over the shared WhatsApp
+// connection until ctx is cancelled
+func (f *fetcher) serveAPI(ctx context.Context, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /fetch", f.requireToken(f.handleFetch))
+	mux.HandleFunc("POST /lookup", f.requireToken(f.handleLookup))
+	mux.HandleFunc("POST /run", f.requireToken(f.handleRun))
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down API server: %v", err)
+		}
+	}()
+
+	log.Printf("Serving fetch API on %s", listener.Addr())
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// requireToken rejects requests without the configured bearer token
+func (f *fetcher) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	expected := []byte("Bearer " + f.cfg.APIToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "missing or invalid bearer token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleFetch fetches the requested number's profile picture and returns it with its metadata
+func (f *fetcher) handleFetch(w http.ResponseWriter, r *http.Request) {
+	var req fetchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBytes)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	req.Number = strings.TrimSpace(req.Number)
+	if req.Number == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "number is required"})
+		return
+	}
+
+	correlationID := correlation.NewID()
+	ctx := correlation.WithID(r.Context(), correlationID)
+	ctx = correlation.WithFields(ctx, "number", req.Number, "operation", "api_fetch")
+	correlation.Logf(ctx, "API fetch requested for %s", req.Number)
+
+	picture, err := f.wa.GetProfilePictureWithInfo(ctx, req.Number)
+	if err != nil {
+		correlation.Logf(ctx, "API fetch for %s failed: %v", req.Number, err)
+		writeJSON(w, fetchErrorStatus(err), apiError{Error: err.Error(), CorrelationID: correlationID})
+		return
+	}
+
+	resp := fetchResponse{
+		Number:        req.Number,
+		PictureID:     picture.ID,
+		Type:          picture.Type,
+		Hash:          storage.ContentHash(picture.Data),
+		Size:          len(picture.Data),
+		FetchedAt:     f.clock.Now(),
+		CorrelationID: correlationID,
+	}
+
+	if name, err := f.wa.ContactName(req.Number); err != nil {
+		correlation.Logf(ctx, "Failed to look up contact name for %s: %v", req.Number, err)
+	} else {
+		resp.Name = name
+	}
+
+	if f.storage != nil {
+		obj, err := f.storeImage(ctx, picture.Data, profileFilename(req.Number, resp.FetchedAt))
+		if err != nil {
+			correlation.Logf(ctx, "Failed to store profile picture: %v", err)
+		} else {
+			resp.ImageURL = obj.URL
+		}
+	}
+	if resp.ImageURL == "" {
+		resp.Image = picture.Data
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleRun runs the full fetch pipeline over the shared connection, posting to
+// Discord exactly as a single run would, and reports every target's outcome
+func (f *fetcher) handleRun(w http.ResponseWriter, r *http.Request) {
+	var req runRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBytes)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	if len(req.Numbers) > maxLookupNumbers {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("at most %d numbers can be run at once", maxLookupNumbers)})
+		return
+	}
+
+	numbers := f.cfg.TargetPhoneNumbers
+	if len(req.Numbers) > 0 {
+		numbers = make([]string, 0, len(req.Numbers))
+		for _, number := range req.Numbers {
+			number = strings.TrimSpace(number)
+			if err := config.ValidateTarget(number); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+				return
+			}
+			numbers = append(numbers, number)
+		}
+	}
+
+	log.Printf("API run requested for %d targets", len(numbers))
+	result := f.fetchNumbers(r.Context(), numbers, 0, time.Time{})
+
+	resp := runResponse{Results: make([]runResult, len(result.Items)), Duration: result.Duration.String()}
+	for i, item := range result.Items {
+		resp.Results[i] = runResult{
+			Number:        item.Number,
+			Changed:       item.Changed,
+			Skipped:       item.Skipped,
+			Unchanged:     item.Unchanged,
+			Suppressed:    item.Suppressed,
+			Removed:       item.Removed,
+			Bytes:         item.Bytes,
+			CorrelationID: item.CorrelationID,
+		}
+		if item.Err != nil {
+			resp.Results[i].Error = item.Err.Error()
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleLookup reports for each requested number whether it is on WhatsApp,
+// its display name and, when storage is configured, a hosted avatar URL.
+// Failures are reported per entry; the request itself only fails on bad input.
+func (f *fetcher) handleLookup(w http.ResponseWriter, r *http.Request) {
+	var req lookupRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBytes)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	if len(req.Numbers) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "numbers is required"})
+		return
+	}
+	if len(req.Numbers) > maxLookupNumbers {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("at most %d numbers can be looked up at once", maxLookupNumbers)})
+		return
+	}
+
+	correlationID := correlation.NewID()
+	ctx := correlation.WithID(r.Context(), correlationID)
+	ctx = correlation.WithFields(ctx, "operation", "api_lookup")
+	correlation.Logf(ctx, "API lookup requested for %d numbers", len(req.Numbers))
+
+	for i, number := range req.Numbers {
+		req.Numbers[i] = strings.TrimSpace(number)
+	}
+
+	results := make([]lookupResult, len(req.Numbers))
+	var registered []string
+	var registeredIndexes []int
+	for i, registration := range f.wa.CheckRegistered(req.Numbers) {
+		result := &results[i]
+		result.Number = registration.PhoneNumber
+		if registration.Err != nil {
+			result.Error = registration.Err.Error()
+			continue
+		}
+
+		result.OnWhatsApp = registration.Registered
+		if !registration.Registered {
+			continue
+		}
+		result.JID = registration.JID.String()
+
+		result.Name = registration.BusinessName
+		result.Business = string(registration.BusinessLevel)
+		if name, err := f.wa.ContactName(registration.PhoneNumber); err != nil {
+			correlation.Logf(ctx, "Failed to look up contact name for %s: %v", registration.PhoneNumber, err)
+		} else if name != "" {
+			result.Name = name
+		}
+
+		registered = append(registered, registration.PhoneNumber)
+		registeredIndexes = append(registeredIndexes, i)
+	}
+
+	// Avatars can only be linked once they are stored somewhere
+	if f.storage != nil && len(registered) > 0 {
+		now := f.clock.Now()
+		pictures, err := f.wa.GetProfilePictures(ctx, registered)
+		if err != nil {
+			correlation.Logf(ctx, "Avatar fetch stopped early: %v", err)
+		}
+		for j, picture := range pictures {
+			result := &results[registeredIndexes[j]]
+			switch {
+			case errors.Is(picture.Err, whatsapp.ErrNoProfilePicture):
+			case picture.Err != nil:
+				result.AvatarError = picture.Err.Error()
+			default:
+				obj, err := f.storeImage(ctx, picture.Picture.Data, profileFilename(picture.PhoneNumber, now))
+				if err != nil {
+					correlation.Logf(ctx, "Failed to store profile picture for %s: %v", picture.PhoneNumber, err)
+					result.AvatarError = err.Error()
+				} else {
+					result.AvatarURL = obj.URL
+				}
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, lookupResponse{Results: results, CorrelationID: correlationID})
+}
+
+// fetchErrorStatus maps a fetch error to the HTTP status returned by the API
+func fetchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, whatsapp.ErrNoProfilePicture):
+		return http.StatusNotFound
+	case errors.Is(err, whatsapp.ErrNotAuthorized), errors.Is(err, whatsapp.ErrPrivacyRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, whatsapp.ErrProfileInfoTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// writeJSON writes v as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write API response: %v", err)
+	}
+}
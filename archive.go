@@ -0,0 +1,193 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-web-wa/pkg/batch"
+	"go-web-wa/pkg/correlation"
+)
+
+// maxArchiveUploadBytes is the largest archive posted to Discord, matching
+// the upload limit of webhooks on servers without boosts
+const maxArchiveUploadBytes = 10 << 20
+
+// archiveEntry is one image in the manifest of a run archive
+type archiveEntry struct {
+	Number    string    `json:"number"`
+	Name      string    `json:"name,omitempty"`
+	Filename  string    `json:"filename"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Size      int       `json:"size"`
+}
+
+// runArchive streams a run's images into a zip file as they are fetched and
+// finishes with manifest.json, so only the manifest is held in memory
+type runArchive struct {
+	mu      sync.Mutex
+	file    *os.File
+	zip     *zip.Writer
+	entries []archiveEntry
+}
+
+// newRunArchive creates the zip file at path
+func newRunArchive(path string) (*runArchive, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	return &runArchive{file: file, zip: zip.NewWriter(file)}, nil
+}
+
+// Add writes an image to the archive. A number already archived in this run,
+// as happens when a failed post is retried, is kept only once.
+func (a *runArchive) Add(entry archiveEntry, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, existing := range a.entries {
+		if existing.Number == entry.Number {
+			return nil
+		}
+	}
+
+	// Images are already compressed, so they are stored as is
+	w, err := a.zip.CreateHeader(&zip.FileHeader{Name: entry.Filename, Method: zip.Store, Modified: entry.FetchedAt})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", entry.Filename, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", entry.Filename, err)
+	}
+	entry.Size = len(data)
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+// Close writes the manifest and closes the archive. It returns how many
+// images were archived.
+func (a *runArchive) Close() (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	manifest, err := json.MarshalIndent(a.entries, "", "  ")
+	if err != nil {
+		a.file.Close()
+		return 0, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	w, err := a.zip.Create("manifest.json")
+	if err == nil {
+		_, err = w.Write(manifest)
+	}
+	if err == nil {
+		err = a.zip.Close()
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return len(a.entries), nil
+}
+
+// openArchive starts collecting the run's images when RUN_ARCHIVE_DIR or
+// RUN_ARCHIVE_POST is set. Without a directory the archive is a temporary
+// file that only lives until it is posted.
+func (f *fetcher) openArchive() error {
+	if f.cfg.RunArchiveDir == "" && !f.cfg.RunArchivePost {
+		return nil
+	}
+
+	filename := fmt.Sprintf("run_%s.zip", f.clock.Now().Format("20060102_150405"))
+	var path string
+	if f.cfg.RunArchiveDir != "" {
+		if err := os.MkdirAll(f.cfg.RunArchiveDir, 0755); err != nil {
+			return fmt.Errorf("failed to create archive directory: %w", err)
+		}
+		path = filepath.Join(f.cfg.RunArchiveDir, filename)
+	} else {
+		dir, err := os.MkdirTemp("", "go-web-wa-archive")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary archive directory: %w", err)
+		}
+		path = filepath.Join(dir, filename)
+	}
+
+	archive, err := newRunArchive(path)
+	if err != nil {
+		return err
+	}
+	f.archive = archive
+	f.archivePath = path
+	return nil
+}
+
+// archiveImage adds a fetched image to the run archive, if one is open
+func (f *fetcher) archiveImage(ctx context.Context, item *batch.FetchItem, filename string, imageData []byte) {
+	if f.archive == nil {
+		return
+	}
+
+	entry := archiveEntry{Number: item.Number, Name: item.Name, Filename: filename, FetchedAt: f.clock.Now()}
+	if err := f.archive.Add(entry, imageData); err != nil {
+		correlation.Logf(ctx, "Failed to archive image for %s: %v", item.Number, err)
+	}
+}
+
+// closeArchive finishes the run archive and posts it to Discord when
+// RUN_ARCHIVE_POST is set. A temporary archive is removed afterwards.
+func (f *fetcher) closeArchive() {
+	if f.archive == nil {
+		return
+	}
+	archive, path := f.archive, f.archivePath
+	f.archive, f.archivePath = nil, ""
+	if f.cfg.RunArchiveDir == "" {
+		defer os.RemoveAll(filepath.Dir(path))
+	}
+
+	count, err := archive.Close()
+	if err != nil {
+		log.Printf("Failed to write run archive: %v", err)
+		return
+	}
+	if count == 0 {
+		log.Printf("No images fetched, skipping run archive")
+		if f.cfg.RunArchiveDir != "" {
+			os.Remove(path)
+		}
+		return
+	}
+	if f.cfg.RunArchiveDir != "" {
+		log.Printf("Archived %d images to %s", count, path)
+	}
+
+	if !f.cfg.RunArchivePost {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf("Failed to read run archive: %v", err)
+		return
+	}
+	if info.Size() > maxArchiveUploadBytes {
+		log.Printf("Run archive is %d bytes, more than Discord accepts (%d); not posting it", info.Size(), maxArchiveUploadBytes)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read run archive: %v", err)
+		return
+	}
+	if err := f.discord.SendFile(data, filepath.Base(path), fmt.Sprintf("Profile pictures of %d targets", count)); err != nil {
+		log.Printf("Failed to post run archive to Discord: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-web-wa/pkg/correlation"
+	"go-web-wa/pkg/state"
+	"go-web-wa/pkg/whatsapp"
+)
+
+// backingOff reports whether phoneNumber failed recently enough that this
+// cycle should leave it alone
+func (f *fetcher) backingOff(ctx context.Context, phoneNumber string) bool {
+	if !f.backoffFailures {
+		return false
+	}
+
+	ns := f.state.Number(phoneNumber)
+	if !f.clock.Now().Before(ns.RetryAfter) {
+		return false
+	}
+	correlation.Logf(ctx, "Skipping %s after %d consecutive failures until %s", phoneNumber, ns.ConsecutiveFailures, ns.RetryAfter.Format(time.RFC3339))
+	return true
+}
+
+// recordOutcome counts consecutive failures of phoneNumber and schedules the
+// next attempt, doubling the delay from FAILURE_BACKOFF_SECONDS. Once
+// FAILURE_ALERT_THRESHOLD is reached it alerts once and waits
+// FAILURE_COOLDOWN_SECONDS between attempts. A success resets the count; a
+// number without a visible picture is an answer, not a failure, so it counts as
+// a success too.
+func (f *fetcher) recordOutcome(ctx context.Context, phoneNumber string, err error) {
+	if !f.backoffFailures || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	if errors.Is(err, whatsapp.ErrNoProfilePicture) || errors.Is(err, whatsapp.ErrPrivacyRestricted) {
+		err = nil
+	}
+	if err == nil && f.state.Number(phoneNumber).ConsecutiveFailures == 0 {
+		return
+	}
+
+	var failures int
+	var alert bool
+	var retryAfter time.Time
+	if updateErr := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+		if err == nil {
+			ns.ConsecutiveFailures = 0
+			ns.RetryAfter = time.Time{}
+			ns.FailureAlerted = false
+			return
+		}
+
+		ns.ConsecutiveFailures++
+		failures = ns.ConsecutiveFailures
+		delay := f.cfg.FailureBackoff
+		for i := 1; i < failures && delay < f.cfg.FailureCooldown; i++ {
+			delay *= 2
+		}
+		delay = min(delay, f.cfg.FailureCooldown)
+		if failures >= f.cfg.FailureThreshold {
+			delay = f.cfg.FailureCooldown
+			if !ns.FailureAlerted {
+				ns.FailureAlerted = true
+				alert = true
+			}
+		}
+		ns.RetryAfter = f.clock.Now().Add(delay)
+		retryAfter = ns.RetryAfter
+	}); updateErr != nil {
+		correlation.Logf(ctx, "Failed to record failure count for %s: %v", phoneNumber, updateErr)
+		return
+	}
+
+	if err == nil {
+		return
+	}
+	correlation.Logf(ctx, "%s failed %d time(s) in a row; next attempt after %s", phoneNumber, failures, retryAfter.Format(time.RFC3339))
+	if alert {
+		f.sendError(ctx, phoneNumber, "Number Keeps Failing", fmt.Sprintf("Fetching %s failed %d times in a row (last error: %v). It will only be retried every %s until it succeeds.", phoneNumber, failures, err, f.cfg.FailureCooldown))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-web-wa/pkg/clock"
+	"go-web-wa/pkg/config"
+	"go-web-wa/pkg/state"
+	"go-web-wa/pkg/whatsapp"
+)
+
+func TestRecordOutcomeTreatsNoPictureAsSuccess(t *testing.T) {
+	stateStore, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fetcher{
+		cfg: &config.Config{
+			FailureBackoff:   time.Minute,
+			FailureCooldown:  time.Hour,
+			FailureThreshold: 5,
+		},
+		state:           stateStore,
+		clock:           clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)),
+		backoffFailures: true,
+	}
+	ctx := context.Background()
+
+	f.recordOutcome(ctx, "+1234567890", errors.New("usync timeout"))
+	if got := stateStore.Number("+1234567890").ConsecutiveFailures; got != 1 {
+		t.Fatalf("ConsecutiveFailures = %d after a failure, want 1", got)
+	}
+
+	for _, answer := range []error{whatsapp.ErrNoProfilePicture, whatsapp.ErrPrivacyRestricted} {
+		f.recordOutcome(ctx, "+1234567890", answer)
+		if got := stateStore.Number("+1234567890"); got.ConsecutiveFailures != 0 || !got.RetryAfter.IsZero() {
+			t.Errorf("after %v ConsecutiveFailures = %d, RetryAfter = %v, want a reset", answer, got.ConsecutiveFailures, got.RetryAfter)
+		}
+		if f.backingOff(ctx, "+1234567890") {
+			t.Errorf("backingOff after %v, want false", answer)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-web-wa/pkg/batch"
+)
+
+// callbackPayload is the body POSTed to RESULT_CALLBACK_URL after every run
+type callbackPayload struct {
+	Started  time.Time        `json:"started"`
+	Duration string           `json:"duration"`
+	Results  []callbackResult `json:"results"`
+}
+
+// callbackResult is the outcome of one target; the image is either hosted at
+// ImageURL or embedded base64-encoded in Image
+type callbackResult struct {
+	Number        string `json:"number"`
+	Name          string `json:"name,omitempty"`
+	Changed       bool   `json:"changed"`
+	ImageURL      string `json:"image_url,omitempty"`
+	Image         []byte `json:"image,omitempty"`
+	Error         string `json:"error,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// sendResultCallback POSTs the run's results to RESULT_CALLBACK_URL. Failures
+// are logged only, so the callback never affects the Discord posts.
+func (f *fetcher) sendResultCallback(ctx context.Context, result batch.FetchResult) {
+	if f.cfg.ResultCallbackURL == "" {
+		return
+	}
+
+	payload := callbackPayload{
+		Started:  result.Started,
+		Duration: result.Duration.String(),
+		Results:  make([]callbackResult, len(result.Items)),
+	}
+	for i, item := range result.Items {
+		payload.Results[i] = callbackResult{
+			Number:        item.Number,
+			Name:          item.Name,
+			Changed:       item.Changed,
+			ImageURL:      item.ImageURL,
+			CorrelationID: item.CorrelationID,
+		}
+		if item.ImageURL == "" {
+			payload.Results[i].Image = item.Image
+		}
+		if item.Err != nil {
+			payload.Results[i].Error = item.Err.Error()
+		}
+	}
+
+	if err := f.postCallback(ctx, payload); err != nil {
+		log.Printf("Failed to send results to RESULT_CALLBACK_URL: %v", err)
+		return
+	}
+	log.Printf("Sent %d results to RESULT_CALLBACK_URL", len(payload.Results))
+}
+
+// postCallback sends payload as JSON and fails on any non-2xx response
+func (f *fetcher) postCallback(ctx context.Context, payload callbackPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+
+	// Deliver even when the run itself was cancelled
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.cfg.ResultCallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("callback returned %d - %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go-web-wa/pkg/clock"
+	"go-web-wa/pkg/config"
+	"go-web-wa/pkg/discord"
+	"go-web-wa/pkg/netproxy"
+	"go-web-wa/pkg/state"
+)
+
+// resendLastImage posts the most recently fetched image to Discord again
+// without touching WhatsApp
+func resendLastImage() int {
+	cfg, err := config.Load()
+	if err != nil {
+		logConfigErrors(err)
+		return exitConfigError
+	}
+
+	stateStore, err := state.Open(cfg.StateFilePath)
+	if err != nil {
+		log.Printf("Failed to open state store: %v", err)
+		return exitPartialFailure
+	}
+
+	lastImage, ok := stateStore.LastImage()
+	if !ok {
+		log.Printf("No cached image to resend. Run a fetch first (with CACHE_LAST_IMAGE enabled).")
+		return exitPartialFailure
+	}
+
+	imageData, err := os.ReadFile(lastImage.Path)
+	if err != nil {
+		log.Printf("Failed to read cached image: %v", err)
+		return exitPartialFailure
+	}
+
+	log.Printf("Resending %s (fetched %s) for %s", lastImage.Filename, lastImage.FetchedAt.Format("2006-01-02 15:04:05"), lastImage.Number)
+	discordClient, code := commandDiscordClient(cfg)
+	if discordClient == nil {
+		return code
+	}
+	if err := discordClient.SendImageWithFile(imageData, lastImage.Filename, lastImage.Number); err != nil {
+		log.Printf("Failed to send image to Discord: %v", err)
+		return exitPartialFailure
+	}
+
+	fmt.Println("Resent last fetched image")
+	return exitSuccess
+}
+
+// testNotify posts a sample of each notification to the configured webhook so
+// the webhook, templates and encoding can be checked without WhatsApp
+func testNotify() int {
+	cfg, err := config.Load()
+	if err != nil {
+		logConfigErrors(err)
+		return exitConfigError
+	}
+
+	discordClient, code := commandDiscordClient(cfg)
+	if discordClient == nil {
+		return code
+	}
+
+	imageData, err := defaultPlaceholder()
+	if err != nil {
+		log.Printf("Failed to render sample image: %v", err)
+		return exitPartialFailure
+	}
+
+	number := cfg.TargetPhoneNumbers[0]
+	samples := []struct {
+		name string
+		send func() error
+	}{
+		{"error", func() error {
+			return discordClient.SendErrorMessage("Test Error", "This is a sample error notification from test-notify.")
+		}},
+		{"success", func() error {
+			return discordClient.SendSuccessMessage("Test Success", "This is a sample success notification from test-notify.")
+		}},
+		{"image", func() error {
+			return discordClient.SendProfileImage(discord.ProfileImage{
+				Data:     imageData,
+				Filename: "test_" + strings.TrimSuffix(profileFilename(number, time.Now().In(cfg.Location)), ".jpg") + ".png",
+				Number:   number,
+				Name:     "Sample Contact",
+				Fields:   []discord.Field{{Name: "Changed Since Last Run", Value: "Possibly", Inline: true}, {Name: "Picture ID", Value: "1234567890", Inline: true}},
+			})
+		}},
+	}
+
+	failed := 0
+	for _, sample := range samples {
+		if err := sample.send(); err != nil {
+			log.Printf("Failed to send %s sample: %v", sample.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Sent %s sample\n", sample.name)
+	}
+	if failed > 0 {
+		return exitPartialFailure
+	}
+	return exitSuccess
+}
+
+// commandDiscordClient builds the default webhook client for commands other
+// than a fetch. On failure it returns nil and the exit code to use.
+func commandDiscordClient(cfg *config.Config) (*discord.WebhookClient, int) {
+	templates, err := discord.NewImageTemplates(cfg.TitleTemplate, cfg.DescriptionTemplate)
+	if err != nil {
+		log.Printf("Invalid embed template: %v", err)
+		return nil, exitConfigError
+	}
+
+	rootCAs, err := netproxy.LoadCertPool(cfg.ExtraCACerts)
+	if err != nil {
+		log.Printf("Failed to load EXTRA_CA_CERTS: %v", err)
+		return nil, exitConfigError
+	}
+	httpClient, err := netproxy.NewHTTPClient(cfg.ProxyURL, 30*time.Second, rootCAs)
+	if err != nil {
+		log.Printf("Failed to configure proxy: %v", err)
+		return nil, exitConfigError
+	}
+
+	return discord.NewWebhookClient(cfg.DiscordWebhookURL,
+		discord.WithClock(clock.InLocation{Clock: clock.Real{}, Location: cfg.Location}),
+		discord.WithHTTPClient(httpClient),
+		discord.WithEncoder(webhookEncoder(cfg)),
+		discord.WithImageTemplates(templates),
+		discord.WithInsecureSkipVerify(cfg.InsecureSkipVerify),
+		discord.WithRetryAttempts(cfg.WebhookRetries),
+		discord.WithMasker(setupMasking(cfg)),
+		discord.WithVersion(buildVersion()),
+	), exitSuccess
+}
+
+// exportState prints the change-detection state as JSON to stdout, or to the
+// file given with -o
+func exportState(args []string) int {
+	flags := flag.NewFlagSet("export-state", flag.ContinueOnError)
+	output := flags.String("o", "", "write the JSON to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitConfigError
+	}
+
+	statePath := config.StateFilePath()
+	if _, err := os.Stat(statePath); err != nil {
+		log.Printf("No state file at %s: %v", statePath, err)
+		return exitPartialFailure
+	}
+
+	stateStore, err := state.Open(statePath)
+	if err != nil {
+		log.Printf("Failed to open state store: %v", err)
+		return exitPartialFailure
+	}
+
+	if *output == "" {
+		if err := stateStore.Export(os.Stdout); err != nil {
+			log.Printf("Failed to export state: %v", err)
+			return exitPartialFailure
+		}
+		return exitSuccess
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Printf("Failed to create %s: %v", *output, err)
+		return exitPartialFailure
+	}
+	if err := stateStore.Export(file); err != nil {
+		file.Close()
+		log.Printf("Failed to export state: %v", err)
+		return exitPartialFailure
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Failed to write %s: %v", *output, err)
+		return exitPartialFailure
+	}
+
+	log.Printf("Exported state from %s to %s", statePath, *output)
+	return exitSuccess
+}
+
+// showHistory prints the recorded picture changes of a number, oldest first
+func showHistory(args []string) int {
+	if len(args) != 1 {
+		log.Println("Usage: history <number>")
+		return exitConfigError
+	}
+
+	statePath := config.StateFilePath()
+	if _, err := os.Stat(statePath); err != nil {
+		log.Printf("No state file at %s: %v", statePath, err)
+		return exitPartialFailure
+	}
+	stateStore, err := state.Open(statePath)
+	if err != nil {
+		log.Printf("Failed to open state store: %v", err)
+		return exitPartialFailure
+	}
+
+	// State is keyed by the number as configured, which may be written with or without +
+	number := strings.TrimSpace(args[0])
+	digits := strings.NewReplacer("+", "", "-", "", " ", "").Replace(number)
+	var history []state.PictureChange
+	for _, key := range []string{number, digits, "+" + digits} {
+		if history = stateStore.Number(key).History; len(history) > 0 {
+			break
+		}
+	}
+	if len(history) == 0 {
+		log.Printf("No picture history recorded for %s", number)
+		return exitSuccess
+	}
+
+	for _, change := range history {
+		fmt.Printf("%s  %-24s %s\n", change.ChangedAt.Format(time.RFC3339), cmp.Or(change.PictureID, "-"), cmp.Or(change.Stored, change.Hash))
+	}
+	return exitSuccess
+}
+
+// validateConfig checks the configuration without connecting to WhatsApp or
+// Discord, printing the effective values with secrets redacted and every
+// problem found. Any arguments are targets, as with fetch.
+func validateConfig(args []string) int {
+	cfg := config.Parse(args)
+
+	var problems []error
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, unwrapJoined(err)...)
+	}
+	if _, err := discord.NewImageTemplates(cfg.TitleTemplate, cfg.DescriptionTemplate); err != nil {
+		problems = append(problems, fmt.Errorf("invalid embed template: %w", err))
+	}
+
+	for _, setting := range cfg.Settings() {
+		fmt.Printf("%s=%s\n", setting.Name, setting.Value)
+	}
+
+	if len(problems) > 0 {
+		logConfigErrors(errors.Join(problems...))
+		return exitConfigError
+	}
+
+	log.Printf("Configuration is valid")
+	return exitSuccess
+}
+
+// logConfigErrors logs every problem found in the configuration, one per line
+func logConfigErrors(err error) {
+	problems := unwrapJoined(err)
+	log.Printf("Configuration has %d problem(s):", len(problems))
+	for _, problem := range problems {
+		log.Printf("  - %v", problem)
+	}
+}
+
+// unwrapJoined splits an errors.Join error into its parts
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"go-web-wa/pkg/config"
+)
+
+// runOnDaemon hands a single run to the --serve daemon at DAEMON_URL so it
+// reuses the daemon's warm WhatsApp connection. It reports false when the
+// daemon can't be reached, and the caller then connects itself.
+func runOnDaemon(cfg *config.Config) (int, bool) {
+	body, err := json.Marshal(runRequest{Numbers: cfg.TargetPhoneNumbers})
+	if err != nil {
+		log.Printf("Failed to encode run request: %v", err)
+		return exitPartialFailure, true
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.DaemonURL, "/")+"/run", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to build run request: %v", err)
+		return exitConfigError, true
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	// The daemon is local, so skip PROXY_URL; MAX_RUN_SECONDS bounds the wait
+	client := &http.Client{Timeout: cfg.MaxRunDuration}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Only a daemon that isn't running is bypassed; one that accepted the
+		// run may still be working on it, and a second run would post twice
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			log.Printf("Daemon at %s unavailable, connecting directly: %v", cfg.DaemonURL, err)
+			return 0, false
+		}
+		log.Printf("Daemon run failed: %v", err)
+		return exitPartialFailure, true
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		log.Printf("Daemon at %s rejected API_TOKEN", cfg.DaemonURL)
+		return exitConfigError, true
+	default:
+		var apiErr apiError
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		log.Printf("Daemon run failed with %s: %s", resp.Status, apiErr.Error)
+		return exitPartialFailure, true
+	}
+
+	var result runResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Printf("Failed to decode daemon response: %v", err)
+		return exitPartialFailure, true
+	}
+
+	failed := 0
+	for _, item := range result.Results {
+		if item.Error != "" {
+			failed++
+			log.Printf("%s failed (correlation %s): %s", item.Number, item.CorrelationID, item.Error)
+		}
+	}
+	if failed > 0 {
+		log.Printf("%d of %d targets failed", failed, len(result.Results))
+		return exitPartialFailure, true
+	}
+
+	log.Printf("Task completed successfully on the daemon in %s!", result.Duration)
+	return exitSuccess, true
+}
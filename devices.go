@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+
+	"go-web-wa/pkg/config"
+	"go-web-wa/pkg/netproxy"
+	"go-web-wa/pkg/whatsapp"
+)
+
+// listDevices prints the devices linked to the paired account
+func listDevices() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	waClient, code := connectSession(ctx)
+	if waClient == nil {
+		return code
+	}
+	defer waClient.Close()
+
+	devices, err := waClient.GetLinkedDevices()
+	if err != nil {
+		log.Printf("Failed to list linked devices: %v", err)
+		return exitPartialFailure
+	}
+
+	for _, device := range devices {
+		role := "linked"
+		switch {
+		case device.Primary:
+			role = "phone"
+		case device.Current:
+			role = "this session"
+		}
+		fmt.Printf("%-40s %s\n", device.JID, role)
+	}
+	return exitSuccess
+}
+
+// unlinkDevice removes the linked device given as the only argument
+func unlinkDevice(args []string) int {
+	if len(args) != 1 {
+		log.Printf("Usage: unlink-device <device JID> (see the devices command)")
+		return exitConfigError
+	}
+	jid, err := types.ParseJID(args[0])
+	if err != nil {
+		log.Printf("Invalid device JID %q: %v", args[0], err)
+		return exitConfigError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	waClient, code := connectSession(ctx)
+	if waClient == nil {
+		return code
+	}
+	defer waClient.Close()
+
+	if err := waClient.RemoveLinkedDevice(ctx, jid); err != nil {
+		log.Printf("Failed to unlink %s: %v", jid, err)
+		return exitPartialFailure
+	}
+
+	fmt.Printf("Unlinked %s\n", jid)
+	return exitSuccess
+}
+
+// readSecrets reads the given secrets in order, honouring their _FILE variants
+func readSecrets(keys ...string) ([]string, error) {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		value, err := config.Secret(key)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// connectSession opens the paired session with any extra options and waits for
+// the connection. On failure it returns a nil client and the exit code to use.
+func connectSession(ctx context.Context, opts ...whatsapp.Option) (*whatsapp.Client, int) {
+	sessionPath := os.Getenv("SESSION_FILE_PATH")
+	if sessionPath == "" {
+		sessionPath = "./sessions/"
+	}
+
+	secrets, err := readSecrets("SESSION_ENCRYPTION_KEY", "SESSION_ENCRYPTION_PREVIOUS_KEY", "PROXY_URL")
+	if err != nil {
+		log.Printf("Failed to read secret: %v", err)
+		return nil, exitConfigError
+	}
+
+	rootCAs, err := netproxy.LoadCertPool(os.Getenv("EXTRA_CA_CERTS"))
+	if err != nil {
+		log.Printf("Failed to load EXTRA_CA_CERTS: %v", err)
+		return nil, exitConfigError
+	}
+
+	opts = append([]whatsapp.Option{
+		whatsapp.WithSessionEncryption(secrets[0], secrets[1]),
+		whatsapp.WithProxy(secrets[2]),
+		whatsapp.WithRootCAs(rootCAs),
+		whatsapp.WithDatabasePath(config.DatabasePath()),
+	}, opts...)
+	waClient, err := whatsapp.NewClient(sessionPath, opts...)
+	if err != nil {
+		log.Printf("Failed to create WhatsApp client: %v", err)
+		return nil, exitPartialFailure
+	}
+
+	if !waClient.IsLoggedIn() {
+		waClient.Close()
+		log.Printf("%v", whatsapp.ErrNotLoggedIn)
+		return nil, exitAuthRequired
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	if err := waClient.Connect(connectCtx); err == nil {
+		err = waClient.WaitForState(connectCtx, whatsapp.StateConnected)
+	}
+	if err != nil {
+		waClient.Close()
+		log.Printf("Failed to connect to WhatsApp: %v", err)
+		return nil, exitPartialFailure
+	}
+	return waClient, exitSuccess
+}
@@ -0,0 +1,876 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-web-wa/pkg/batch"
+	"go-web-wa/pkg/clock"
+	"go-web-wa/pkg/config"
+	"go-web-wa/pkg/correlation"
+	"go-web-wa/pkg/discord"
+	"go-web-wa/pkg/imageutil"
+	"go-web-wa/pkg/publisher"
+	"go-web-wa/pkg/state"
+	"go-web-wa/pkg/storage"
+	"go-web-wa/pkg/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// publishTimeout bounds publishing one change event, so a slow message bus
+// can't hold up shutdown
+const publishTimeout = 15 * time.Second
+
+// errDiscordDelivery marks failures to post the image to Discord
+var errDiscordDelivery = errors.New("failed to send image to Discord")
+
+// fetcher bundles the dependencies of the fetch pipeline
+type fetcher struct {
+	cfg     *config.Config
+	wa      *whatsapp.Client
+	discord *discord.WebhookClient
+	state   *state.Store
+	clock   clock.Clock
+	// routed holds the clients of TARGET_WEBHOOK_MAP webhooks by URL
+	routed map[string]*discord.WebhookClient
+	// publisher receives change events; nil when PUBLISHER is unset
+	publisher publisher.Publisher
+	// publishes tracks change events still being published in the background
+	publishes sync.WaitGroup
+	// storage archives fetched images; nil when storage is disabled
+	storage storage.Backend
+	// backoffFailures defers numbers that keep failing; only set for --watch
+	backoffFailures bool
+	// httpClient makes outgoing calls such as RESULT_CALLBACK_URL, through PROXY_URL if set
+	httpClient *http.Client
+	// archive bundles a single run's images; nil unless the run is archived
+	archive     *runArchive
+	archivePath string
+	// progress is told about every finished target of a run; nil disables it
+	progress batch.ProgressFunc
+
+	// lastImageMu serializes writes to the last image cache between concurrent fetches
+	lastImageMu sync.Mutex
+
+	// gallery collects images to post together when POST_AS_GALLERY is set
+	galleryMu sync.Mutex
+	gallery   []galleryEntry
+}
+
+// galleryEntry is an image waiting for the gallery, with what is needed to
+// record its delivery once the gallery is sent
+type galleryEntry struct {
+	image         discord.GalleryImage
+	number        string
+	changed       bool
+	correlationID string
+}
+
+// watch fetches every target on each poll interval until ctx is cancelled
+func (f *fetcher) watch(ctx context.Context) int {
+	log.Printf("Watching %d targets every %v", len(f.cfg.TargetPhoneNumbers), f.cfg.PollInterval)
+
+	// Spread the targets over the whole interval instead of fetching them at once
+	var window time.Duration
+	if f.cfg.PollSpread {
+		window = f.cfg.PollInterval
+	}
+
+	// quietSince is when the last change, failure or quiet summary was posted
+	quietSince := f.clock.Now()
+	for {
+		// Schedule from the start of the cycle so spreading doesn't stretch it
+		cycleStart := f.clock.Now()
+		result := f.fetchTargets(ctx, window, cycleStart)
+		if len(result.Failed()) > 0 {
+			log.Printf("%d of %d targets failed this cycle", len(result.Failed()), len(result.Items))
+			logErrorGroups(result)
+		}
+		quietSince = f.postQuietSummary(result, quietSince)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down watcher")
+			return exitSuccess
+		case <-f.clock.After(cycleStart.Add(f.cfg.PollInterval + f.jitter()).Sub(f.clock.Now())):
+		}
+	}
+}
+
+// jitter returns a random delay of up to POLL_JITTER_SECONDS
+func (f *fetcher) jitter() time.Duration {
+	if f.cfg.PollJitter <= 0 {
+		return 0
+	}
+	return rand.N(f.cfg.PollJitter)
+}
+
+// watchPresence fetches a target whenever it comes online until ctx is cancelled.
+// Targets are still fetched at most once per poll interval.
+func (f *fetcher) watchPresence(ctx context.Context) int {
+	log.Printf("Watching %d targets for online status", len(f.cfg.TargetPhoneNumbers))
+
+	// Hand targets over to this goroutine so the event handler never blocks
+	cameOnline := make(chan string, len(f.cfg.TargetPhoneNumbers))
+	f.wa.OnOnline(func(phoneNumber string) {
+		select {
+		case cameOnline <- phoneNumber:
+		default:
+			log.Printf("Fetch queue full, dropping online event for %s", phoneNumber)
+		}
+	})
+
+	if err := f.wa.SubscribePresence(f.cfg.TargetPhoneNumbers...); err != nil {
+		log.Printf("Failed to subscribe to presence: %v", err)
+		sendErrorToDiscord(f.discord, "Presence Error", fmt.Sprintf("Failed to subscribe to presence: %v", err))
+		return exitPartialFailure
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down watcher")
+			return exitSuccess
+		case phoneNumber := <-cameOnline:
+			item := f.fetchAndSend(ctx, phoneNumber, f.clock.Now().Add(-f.cfg.PollInterval))
+			if item.Err != nil {
+				log.Printf("Fetch for %s failed: %v", phoneNumber, item.Err)
+				if !f.cfg.SendSummary {
+					f.reportFetchError(correlation.WithID(ctx, item.CorrelationID), phoneNumber, item.Err)
+				}
+			}
+			if f.cfg.TrackStatus {
+				f.checkStatus(correlation.WithID(ctx, item.CorrelationID), phoneNumber)
+			}
+			f.flushGallery()
+		}
+	}
+}
+
+// postQuietSummary posts the summary of a cycle without changes or failures
+// once QUIET_SUMMARY_INTERVAL_SECONDS have passed since quietSince, and
+// returns when the quiet period now starts
+func (f *fetcher) postQuietSummary(result batch.FetchResult, quietSince time.Time) time.Time {
+	now := f.clock.Now()
+	if result.ChangedCount() > 0 || len(result.Failed()) > 0 {
+		return now
+	}
+	// SEND_SUMMARY already posts every cycle
+	if !f.cfg.QuietSummary || f.cfg.SendSummary || now.Sub(quietSince) < f.cfg.QuietInterval {
+		return quietSince
+	}
+
+	if err := f.discord.SendQuietSummary(result, now.Sub(quietSince)); err != nil {
+		log.Printf("Failed to send quiet summary to Discord: %v", err)
+		return quietSince
+	}
+	return now
+}
+
+// fetchTargets fetches and sends every configured target, posting a summary
+// at the end when enabled. A non-zero window staggers the targets evenly
+// across it, each with its own jitter, so they aren't all requested at once.
+// Numbers already posted since cycleStart are skipped; a zero cycleStart skips none.
+func (f *fetcher) fetchTargets(ctx context.Context, window time.Duration, cycleStart time.Time) batch.FetchResult {
+	return f.fetchNumbers(ctx, f.cfg.TargetPhoneNumbers, window, cycleStart)
+}
+
+// fetchNumbers is fetchTargets for the given numbers
+func (f *fetcher) fetchNumbers(ctx context.Context, numbers []string, window time.Duration, cycleStart time.Time) batch.FetchResult {
+	result := batch.FetchResult{
+		Started: f.clock.Now(),
+		Items:   make([]batch.FetchItem, len(numbers)),
+	}
+
+	// Report progress as targets finish, in whatever order that happens
+	var progressMu sync.Mutex
+	progress := batch.Progress{Total: len(numbers)}
+	finished := func(item batch.FetchItem) {
+		if f.progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		progress.Number = item.Number
+		progress.Done++
+		if item.Err != nil {
+			progress.Failed++
+		} else {
+			progress.Succeeded++
+		}
+		f.progress(progress)
+	}
+
+	// Fetch up to FETCH_CONCURRENCY targets at once, keeping results in target order
+	sem := make(chan struct{}, f.cfg.FetchConcurrency)
+	var wg sync.WaitGroup
+	for i, phoneNumber := range numbers {
+		if window > 0 {
+			offset := window*time.Duration(i)/time.Duration(len(numbers)) + f.jitter()
+			select {
+			case <-ctx.Done():
+			case <-f.clock.After(result.Started.Add(offset).Sub(f.clock.Now())):
+			}
+		}
+
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			result.Items[i] = batch.FetchItem{Number: phoneNumber, Err: ctx.Err()}
+			finished(result.Items[i])
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result.Items[i] = f.fetchAndSend(ctx, phoneNumber, cycleStart)
+			if f.cfg.TrackStatus {
+				f.checkStatus(correlation.WithID(ctx, result.Items[i].CorrelationID), phoneNumber)
+			}
+			finished(result.Items[i])
+		}()
+	}
+	wg.Wait()
+	f.flushGallery()
+	result.Duration = f.clock.Now().Sub(result.Started)
+
+	// The summary lists failures itself
+	if f.cfg.SendSummary {
+		if err := f.discord.SendSummary(result); err != nil {
+			log.Printf("Failed to send summary to Discord: %v", err)
+		}
+	} else {
+		f.reportErrorGroups(ctx, result)
+	}
+	f.sendResultCallback(ctx, result)
+	f.writeReport(result)
+
+	return result
+}
+
+// fetchAndSend fetches one profile picture and posts it to Discord, retrying
+// transient failures and skipping numbers already posted since cycleStart.
+// A zero cycleStart never skips, for runs that aren't part of a watch cycle.
+func (f *fetcher) fetchAndSend(ctx context.Context, phoneNumber string, cycleStart time.Time) batch.FetchItem {
+	item := batch.FetchItem{Number: phoneNumber}
+
+	// Tag every log line and error message of this number's fetch with one ID
+	item.CorrelationID = correlation.NewID()
+	ctx = correlation.WithID(ctx, item.CorrelationID)
+	ctx = correlation.WithFields(ctx, "number", phoneNumber, "operation", "fetch")
+	correlation.Logf(ctx, "Processing %s", phoneNumber)
+
+	if lastNotified := f.state.Number(phoneNumber).LastNotified; !cycleStart.IsZero() && !lastNotified.Before(cycleStart) {
+		correlation.Logf(ctx, "Profile picture for %s already sent this cycle at %s, skipping", phoneNumber, lastNotified.Format(time.RFC3339))
+		item.Skipped = true
+		return item
+	}
+	if f.backingOff(ctx, phoneNumber) {
+		item.Skipped = true
+		return item
+	}
+
+	backoff := f.cfg.FetchRetryBackoff
+	var err error
+	for attempt := 1; attempt <= f.cfg.FetchRetryAttempts; attempt++ {
+		err = f.fetchOnce(ctx, &item)
+		if err == nil || isPermanentFetchError(err) || attempt == f.cfg.FetchRetryAttempts {
+			break
+		}
+
+		correlation.Logf(ctx, "Attempt %d/%d for %s failed: %v. Retrying in %v...", attempt, f.cfg.FetchRetryAttempts, phoneNumber, err, backoff)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+			continue
+		}
+		break
+	}
+
+	// Announce a removed picture once; after that the number is treated like any
+	// other number without a picture
+	if errors.Is(err, whatsapp.ErrNoProfilePicture) && f.state.Number(phoneNumber).PictureID != "" {
+		err = f.reportRemoval(ctx, &item)
+		item.Removed = err == nil
+	}
+
+	// Keep the channel consistent by posting a placeholder for numbers without a picture
+	if errors.Is(err, whatsapp.ErrNoProfilePicture) && f.cfg.SendPlaceholder && f.cfg.PostImages {
+		err = f.sendPlaceholder(ctx, phoneNumber)
+	}
+
+	f.recordOutcome(ctx, phoneNumber, err)
+	if err != nil {
+		item.Err = err
+		return item
+	}
+
+	if item.Unchanged || item.Suppressed || item.Queued {
+		return item
+	}
+
+	f.recordNotified(ctx, phoneNumber, item.Changed)
+	return item
+}
+
+// recordNotified remembers that phoneNumber's picture was posted, so retries
+// within this cycle don't post it again
+func (f *fetcher) recordNotified(ctx context.Context, phoneNumber string, changed bool) {
+	if err := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+		if changed || ns.LastNotified.Before(ns.PictureFirstSeen) {
+			ns.ChangeNotified = f.clock.Now()
+		}
+		ns.LastNotified = f.clock.Now()
+	}); err != nil {
+		correlation.Logf(ctx, "Failed to record notification for %s: %v", phoneNumber, err)
+	}
+}
+
+// fetchOnce performs a single fetch, store and notify attempt, recording the outcome in item
+func (f *fetcher) fetchOnce(ctx context.Context, item *batch.FetchItem) error {
+	phoneNumber := item.Number
+
+	previous := f.state.Number(phoneNumber)
+
+	// Let WhatsApp tell us the picture is unchanged instead of downloading it
+	// again, but only once the current picture has been delivered; otherwise a
+	// failed post would never be retried
+	existingID := ""
+	if f.cfg.SkipUnchanged && !previous.LastNotified.Before(previous.PictureFirstSeen) {
+		existingID = previous.PictureID
+	}
+
+	// Fetch profile picture
+	correlation.Logf(ctx, "Fetching profile picture for: %s", phoneNumber)
+	picture, err := f.wa.GetProfilePictureIfChanged(ctx, phoneNumber, existingID)
+	if errors.Is(err, whatsapp.ErrPictureUnchanged) {
+		correlation.Logf(ctx, "Profile picture for %s is unchanged (ID %s), skipping download", phoneNumber, existingID)
+		item.Unchanged = true
+		if err := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+			ns.LastFetched = f.clock.Now()
+		}); err != nil {
+			correlation.Logf(ctx, "Failed to record fetch for %s: %v", phoneNumber, err)
+		}
+		return nil
+	}
+	if err != nil {
+		correlation.Logf(ctx, "Failed to fetch profile picture: %v", err)
+		return err
+	}
+	imageData := picture.Data
+
+	correlation.Logf(ctx, "Successfully fetched profile picture (ID %s)", picture.ID)
+	f.resolveName(item)
+
+	// Compare against the previous fetch; an earlier attempt may already have recorded the hash.
+	// WhatsApp doesn't say when a picture changed, so the first time a picture ID
+	// is seen stands in for the change time.
+	hash := storage.ContentHash(imageData)
+	item.Bytes = len(imageData)
+	correlation.Logf(ctx, "Image for %s has SHA-256 %s", phoneNumber, hash)
+	item.Changed = previous.LastHash != hash || previous.PictureID != picture.ID
+	firstSeen := previous.PictureFirstSeen
+	if previous.PictureID != picture.ID || firstSeen.IsZero() {
+		firstSeen = f.clock.Now()
+	}
+
+	// Hold back a new picture shortly after the last one was posted; it is
+	// still recorded below and posted by the first fetch after the cooldown
+	if f.cfg.ChangeCooldown > 0 && (item.Changed || previous.LastNotified.Before(firstSeen)) {
+		if since := f.clock.Now().Sub(previous.ChangeNotified); since < f.cfg.ChangeCooldown {
+			slog.DebugContext(ctx, fmt.Sprintf("Suppressing change for %s: last change posted %s ago, cooldown is %s", phoneNumber, since.Round(time.Second), f.cfg.ChangeCooldown))
+			item.Suppressed = true
+		}
+	}
+	if item.Changed && !item.Suppressed {
+		f.publishChange(ctx, item, picture, hash, previous.LastHash)
+	}
+	if err := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+		ns.LastFetched = f.clock.Now()
+		ns.LastHash = hash
+		ns.PictureID = picture.ID
+		ns.PictureFirstSeen = firstSeen
+	}); err != nil {
+		correlation.Logf(ctx, "Failed to record image hash for %s: %v", phoneNumber, err)
+	}
+
+	// Generate filename
+	filename := profileFilename(phoneNumber, f.clock.Now())
+
+	// Keep a copy for the resend command
+	if f.cfg.LastImagePath != "" {
+		if err := f.cacheLastImage(phoneNumber, filename, imageData); err != nil {
+			correlation.Logf(ctx, "Failed to cache last image: %v", err)
+		}
+	}
+
+	// Store the image, reusing an identical object if one is already stored
+	var storedURL, storedLocation string
+	if f.storage != nil {
+		obj, err := f.storeImage(ctx, imageData, filename)
+		if err != nil {
+			correlation.Logf(ctx, "Failed to store profile picture: %v", err)
+			f.sendError(ctx, phoneNumber, "Storage Error", fmt.Sprintf("Failed to store profile picture for %s: %v", phoneNumber, err))
+		} else {
+			storedURL = obj.URL
+			storedLocation = cmp.Or(obj.URL, obj.Path)
+		}
+	}
+
+	f.archiveImage(ctx, item, filename, imageData)
+
+	if item.Changed {
+		f.recordHistory(ctx, phoneNumber, state.PictureChange{
+			ChangedAt: firstSeen,
+			PictureID: picture.ID,
+			Hash:      hash,
+			Stored:    storedLocation,
+		})
+	}
+
+	// Hand the original image to the result callback
+	item.ImageURL = storedURL
+	if f.cfg.ResultCallbackURL != "" && storedURL == "" {
+		item.Image = picture.Data
+	}
+
+	if item.Suppressed {
+		return nil
+	}
+
+	if !f.cfg.PostImages {
+		return nil
+	}
+
+	// Stamp and shrink the posted copy only; the hash and stored image stay original
+	imageData = f.watermark(ctx, imageData)
+	imageData = f.compress(ctx, imageData)
+
+	// Attach contact details when WhatsApp provides them
+	fields := pictureFields(picture, firstSeen, item.Changed)
+	if item.Name != "" {
+		fields = append(fields, discord.Field{Name: "Name", Value: item.Name, Inline: true})
+	}
+	if storedURL != "" {
+		fields = append(fields, discord.Field{Name: "Stored Copy", Value: storedLinkText(storedURL, f.cfg.SignedURLExpiry, f.cfg.StorageBackend == "s3")})
+	}
+
+	// Gallery images are posted together once the batch is done
+	if f.cfg.PostAsGallery {
+		label := item.Label
+		if item.Name != "" {
+			label = fmt.Sprintf("%s (%s)", item.Name, phoneNumber)
+		}
+		f.galleryMu.Lock()
+		f.gallery = append(f.gallery, galleryEntry{
+			image:         discord.GalleryImage{Filename: filename, Data: imageData, Label: label},
+			number:        phoneNumber,
+			changed:       item.Changed,
+			correlationID: item.CorrelationID,
+		})
+		f.galleryMu.Unlock()
+		item.Queued = true
+		return nil
+	}
+
+	// Channels have no user info
+	if !whatsapp.IsNewsletter(phoneNumber) {
+		if userInfo, err := f.wa.GetUserInfo(phoneNumber); err != nil {
+			correlation.Logf(ctx, "Failed to get user info for %s: %v", phoneNumber, err)
+		} else {
+			fields = append(fields, userInfoFields(userInfo)...)
+		}
+	}
+
+	// Send image to Discord
+	correlation.Logf(ctx, "Sending profile picture to Discord...")
+	if err := f.discordFor(phoneNumber).SendProfileImage(discord.ProfileImage{
+		Data:     imageData,
+		Filename: filename,
+		Number:   phoneNumber,
+		Name:     item.Label,
+		Fields:   fields,
+	}); err != nil {
+		correlation.Logf(ctx, "Failed to send image to Discord: %v", err)
+		return fmt.Errorf("%w: %v", errDiscordDelivery, err)
+	}
+
+	// Send success message
+	correlation.Logf(ctx, "Profile picture sent successfully!")
+	f.discordFor(phoneNumber).SendSuccessMessage(
+		"Profile Picture Fetched",
+		fmt.Sprintf("Successfully fetched and sent profile picture for %s", phoneNumber),
+	)
+
+	return nil
+}
+
+// reportRemoval posts that item's number removed their profile picture and
+// forgets the picture, so the removal is only announced once
+func (f *fetcher) reportRemoval(ctx context.Context, item *batch.FetchItem) error {
+	phoneNumber := item.Number
+	previous := f.state.Number(phoneNumber)
+	correlation.Logf(ctx, "Profile picture for %s was removed (last ID %s)", phoneNumber, previous.PictureID)
+
+	f.resolveName(item)
+	if err := f.discordFor(phoneNumber).SendPictureRemoved(phoneNumber, item.Name, previous.PictureFirstSeen); err != nil {
+		return fmt.Errorf("%w: %v", errDiscordDelivery, err)
+	}
+
+	if err := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+		ns.PictureID = ""
+		ns.LastHash = ""
+		ns.PictureFirstSeen = time.Time{}
+		ns.PictureRemovedAt = f.clock.Now()
+	}); err != nil {
+		correlation.Logf(ctx, "Failed to record removal for %s: %v", phoneNumber, err)
+	}
+	return nil
+}
+
+// resolveName looks up the display name of item's number unless an earlier
+// attempt already did, so the posts, archive and result callback of one fetch
+// share a single lookup
+func (f *fetcher) resolveName(item *batch.FetchItem) {
+	if item.Label != "" {
+		return
+	}
+	label, isNumber := f.wa.DisplayName(item.Number)
+	item.Label = label
+	if !isNumber {
+		item.Name = label
+	}
+}
+
+// sendPlaceholder posts the placeholder image for a number without a profile picture
+func (f *fetcher) sendPlaceholder(ctx context.Context, phoneNumber string) error {
+	imageData, source, err := placeholderImage(f.cfg.PlaceholderImagePath)
+	if err != nil {
+		return err
+	}
+
+	filename := "no_picture_" + strings.TrimSuffix(profileFilename(phoneNumber, f.clock.Now()), ".jpg") + filepath.Ext(source)
+
+	correlation.Logf(ctx, "No profile picture for %s, sending placeholder", phoneNumber)
+	if err := f.discordFor(phoneNumber).SendProfileImage(discord.ProfileImage{
+		Data:        imageData,
+		Filename:    filename,
+		Number:      phoneNumber,
+		Title:       "No WhatsApp Profile Image",
+		Description: fmt.Sprintf("No profile picture found for: %s; showing a placeholder", phoneNumber),
+	}); err != nil {
+		return fmt.Errorf("%w: %v", errDiscordDelivery, err)
+	}
+	return nil
+}
+
+// flushGallery posts the collected gallery images, if any, as one gallery per
+// Discord webhook, so TARGET_WEBHOOK_MAP routing applies as it does to single
+// posts. Deliveries are recorded once their gallery is sent; images of a
+// failed gallery stay undelivered, so the next run posts them again.
+func (f *fetcher) flushGallery() {
+	f.galleryMu.Lock()
+	entries := f.gallery
+	f.gallery = nil
+	f.galleryMu.Unlock()
+
+	// Group by webhook, keeping the order in which the webhooks first appear
+	var clients []*discord.WebhookClient
+	groups := make(map[*discord.WebhookClient][]galleryEntry)
+	for _, entry := range entries {
+		client := f.discordFor(entry.number)
+		if _, ok := groups[client]; !ok {
+			clients = append(clients, client)
+		}
+		groups[client] = append(groups[client], entry)
+	}
+
+	for _, client := range clients {
+		group := groups[client]
+		images := make([]discord.GalleryImage, len(group))
+		for i, entry := range group {
+			images[i] = entry.image
+		}
+		log.Printf("Sending %d profile pictures to Discord as a gallery...", len(images))
+		if err := client.SendGallery(images); err != nil {
+			log.Printf("Failed to send gallery to Discord: %v", err)
+			sendErrorToDiscord(client, "Discord Error", fmt.Sprintf("Failed to send gallery of %d profile pictures: %v", len(images), err))
+			continue
+		}
+		for _, entry := range group {
+			f.recordNotified(correlation.WithID(context.Background(), entry.correlationID), entry.number, entry.changed)
+		}
+	}
+}
+
+// reportErrorGroups posts one Discord error per distinct failure in a batch,
+// so a shared root cause isn't repeated for every number
+func (f *fetcher) reportErrorGroups(ctx context.Context, result batch.FetchResult) {
+	for _, group := range result.ErrorGroups() {
+		if group.Count() == 1 {
+			groupCtx := ctx
+			if len(group.CorrelationIDs) == 1 {
+				groupCtx = correlation.WithID(ctx, group.CorrelationIDs[0])
+			}
+			f.reportFetchError(groupCtx, group.Numbers[0], group.Err)
+			continue
+		}
+		f.sendError(ctx, "", fetchErrorTitle(group.Err), fmt.Sprintf("%s\nNumbers: %s", group, strings.Join(group.Numbers, ", ")))
+	}
+}
+
+// logErrorGroups logs one line per distinct failure in a batch
+func logErrorGroups(result batch.FetchResult) {
+	for _, group := range result.ErrorGroups() {
+		log.Printf("%s (%s)", group, strings.Join(group.Numbers, ", "))
+	}
+}
+
+// fetchErrorTitle returns the Discord error title for a fetch failure
+func fetchErrorTitle(err error) string {
+	switch {
+	case errors.Is(err, whatsapp.ErrProfileInfoTimeout):
+		return "Profile Picture Timeout"
+	case errors.Is(err, whatsapp.ErrPrivacyRestricted):
+		return "Profile Picture Hidden"
+	case errors.Is(err, whatsapp.ErrNoProfilePicture):
+		return "No Profile Picture"
+	case errors.Is(err, errDiscordDelivery):
+		return "Discord Error"
+	default:
+		return "Profile Picture Error"
+	}
+}
+
+// reportFetchError posts the final failure for a number to Discord
+func (f *fetcher) reportFetchError(ctx context.Context, phoneNumber string, err error) {
+	switch {
+	case errors.Is(err, whatsapp.ErrProfileInfoTimeout):
+		f.sendError(ctx, phoneNumber, fetchErrorTitle(err), fmt.Sprintf("WhatsApp did not answer the profile picture lookup for %s in time: %v", phoneNumber, err))
+	case errors.Is(err, whatsapp.ErrPrivacyRestricted):
+		f.sendError(ctx, phoneNumber, fetchErrorTitle(err), fmt.Sprintf("The profile picture for %s is hidden by privacy settings", phoneNumber))
+	case errors.Is(err, whatsapp.ErrNoProfilePicture):
+		f.sendError(ctx, phoneNumber, fetchErrorTitle(err), fmt.Sprintf("No profile picture found for %s", phoneNumber))
+	case errors.Is(err, errDiscordDelivery):
+		f.sendError(ctx, phoneNumber, fetchErrorTitle(err), err.Error())
+	default:
+		f.sendError(ctx, phoneNumber, fetchErrorTitle(err), fmt.Sprintf("Failed to fetch profile picture for %s: %v", phoneNumber, err))
+	}
+}
+
+// sendError posts an error to Discord tagged with the correlation ID from ctx,
+// on phoneNumber's webhook or the default one when phoneNumber is empty
+func (f *fetcher) sendError(ctx context.Context, phoneNumber, title, message string) {
+	if err := f.discordFor(phoneNumber).SendErrorMessageWithID(title, message, correlation.FromContext(ctx)); err != nil {
+		correlation.Logf(ctx, "Failed to send error message to Discord: %v", err)
+	}
+}
+
+// discordFor returns the client for phoneNumber's TARGET_WEBHOOK_MAP webhook,
+// falling back to the default webhook
+func (f *fetcher) discordFor(phoneNumber string) *discord.WebhookClient {
+	if client, ok := f.routed[f.cfg.WebhookURLFor(phoneNumber)]; ok {
+		return client
+	}
+	return f.discord
+}
+
+// isPermanentFetchError reports whether retrying the fetch cannot help
+func isPermanentFetchError(err error) bool {
+	return errors.Is(err, whatsapp.ErrPrivacyRestricted) ||
+		errors.Is(err, whatsapp.ErrNoProfilePicture) ||
+		errors.Is(err, context.Canceled)
+}
+
+// recordHistory appends a detected picture change to the number's history
+func (f *fetcher) recordHistory(ctx context.Context, phoneNumber string, change state.PictureChange) {
+	if err := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+		ns.History = append(ns.History, change)
+	}); err != nil {
+		correlation.Logf(ctx, "Failed to record picture history for %s: %v", phoneNumber, err)
+	}
+}
+
+// storeImage uploads the image to the configured storage backend with content-hash deduplication
+func (f *fetcher) storeImage(ctx context.Context, imageData []byte, filename string) (*storage.Object, error) {
+	uploader := storage.NewUploader(f.storage, f.state)
+	obj, err := uploader.Upload(ctx, "avatars/"+filename, imageData, "image/jpeg")
+	if err != nil {
+		return nil, err
+	}
+
+	if obj.Deduplicated {
+		correlation.Logf(ctx, "Profile picture already stored as %s", obj.Path)
+	} else {
+		correlation.Logf(ctx, "Stored profile picture as %s", obj.Path)
+	}
+	return obj, nil
+}
+
+// watermark stamps WATERMARK_TEXT onto a posted image, posting it unmarked if that fails
+func (f *fetcher) watermark(ctx context.Context, imageData []byte) []byte {
+	if f.cfg.WatermarkText == "" {
+		return imageData
+	}
+	corner, _ := imageutil.ParseCorner(f.cfg.WatermarkPosition) // validated by config.Load
+	marked, err := imageutil.Watermark(imageData, f.cfg.WatermarkText, corner)
+	if err != nil {
+		correlation.Logf(ctx, "Failed to watermark image, posting it unmarked: %v", err)
+		return imageData
+	}
+	return marked
+}
+
+// storedLinkText renders the link to a stored image, noting when a signed URL expires
+func storedLinkText(storedURL string, expiry time.Duration, signed bool) string {
+	if !signed {
+		return fmt.Sprintf("[Open](%s)", storedURL)
+	}
+	return fmt.Sprintf("[Open](%s) (link expires in %s)", storedURL, expiry)
+}
+
+// compress shrinks images above MAX_IMAGE_BYTES so Discord accepts them,
+// posting the original when that fails
+func (f *fetcher) compress(ctx context.Context, imageData []byte) []byte {
+	if f.cfg.MaxImageBytes == 0 || len(imageData) <= f.cfg.MaxImageBytes {
+		return imageData
+	}
+	compressed, err := imageutil.Compress(imageData, f.cfg.MaxImageBytes)
+	if err != nil {
+		correlation.Logf(ctx, "Failed to compress image, posting the original: %v", err)
+		return imageData
+	}
+	correlation.Logf(ctx, "Compressed image from %d to %d bytes", len(imageData), len(compressed))
+	if len(compressed) > f.cfg.MaxImageBytes {
+		correlation.Logf(ctx, "Image still exceeds MAX_IMAGE_BYTES (%d bytes) at the lowest quality", f.cfg.MaxImageBytes)
+	}
+	return compressed
+}
+
+// publishChange announces a changed picture on the message bus in the
+// background, so the bus never delays the Discord post. It runs once per change
+// because later attempts compare against the hash recorded here.
+func (f *fetcher) publishChange(ctx context.Context, item *batch.FetchItem, picture *whatsapp.ProfilePicture, hash, previousHash string) {
+	if f.publisher == nil {
+		return
+	}
+
+	payload, err := json.Marshal(publisher.ChangeEvent{
+		Number:        item.Number,
+		PictureID:     picture.ID,
+		Hash:          hash,
+		PreviousHash:  previousHash,
+		Size:          len(picture.Data),
+		DetectedAt:    f.clock.Now(),
+		CorrelationID: item.CorrelationID,
+	})
+	if err != nil {
+		correlation.Logf(ctx, "Failed to encode change event for %s: %v", item.Number, err)
+		return
+	}
+
+	f.publishes.Add(1)
+	go func() {
+		defer f.publishes.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+		defer cancel()
+
+		if err := f.publisher.Publish(ctx, f.cfg.PublisherSubject, payload); err != nil {
+			correlation.Logf(ctx, "Failed to publish change event for %s: %v", item.Number, err)
+			return
+		}
+		correlation.Logf(ctx, "Published change event for %s to %s", item.Number, f.cfg.PublisherSubject)
+	}()
+}
+
+// pictureFields renders the picture metadata and change indicator as embed fields
+func pictureFields(picture *whatsapp.ProfilePicture, firstSeen time.Time, changed bool) []discord.Field {
+	changedText := "No"
+	if changed {
+		changedText = "Possibly"
+	}
+
+	fields := []discord.Field{{Name: "Changed Since Last Run", Value: changedText, Inline: true}}
+	if picture.ID != "" {
+		fields = append(fields,
+			discord.Field{Name: "Picture ID", Value: picture.ID, Inline: true},
+			discord.Field{Name: "First Seen", Value: fmt.Sprintf("<t:%d:R>", firstSeen.Unix()), Inline: true},
+		)
+	}
+	return fields
+}
+
+// userInfoFields renders a contact's user info as embed fields, omitting anything missing
+func userInfoFields(info *types.UserInfo) []discord.Field {
+	var fields []discord.Field
+
+	if business := whatsapp.BusinessOf(info.VerifiedName); business != nil {
+		fields = append(fields, discord.Field{Name: "Business", Value: businessBadge(business), Inline: true})
+	}
+
+	if len(info.Devices) > 0 {
+		fields = append(fields, discord.Field{Name: "Devices", Value: strconv.Itoa(len(info.Devices)), Inline: true})
+	}
+
+	if status := strings.TrimSpace(info.Status); status != "" {
+		fields = append(fields, discord.Field{Name: "Status", Value: status})
+	}
+
+	return fields
+}
+
+// businessBadge marks an official business with a check mark so it stands
+// out from a business that only named itself
+func businessBadge(business *whatsapp.Business) string {
+	if business.Level == whatsapp.BusinessOfficial {
+		return "✅ " + business.Name + " (official)"
+	}
+	return business.Name + " (unverified)"
+}
+
+// cacheLastImage writes the image to the last image cache and records it in the state
+func (f *fetcher) cacheLastImage(phoneNumber, filename string, imageData []byte) error {
+	f.lastImageMu.Lock()
+	defer f.lastImageMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.cfg.LastImagePath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	if err := os.WriteFile(f.cfg.LastImagePath, imageData, 0600); err != nil {
+		return fmt.Errorf("failed to write cached image: %w", err)
+	}
+
+	return f.state.SetLastImage(state.LastImage{
+		Number:    phoneNumber,
+		Filename:  filename,
+		Path:      f.cfg.LastImagePath,
+		FetchedAt: f.clock.Now(),
+	})
+}
+
+// profileFilename builds the attachment filename for a fetched profile picture
+func profileFilename(phoneNumber string, fetchedAt time.Time) string {
+	// Targets may be full JIDs or channel links, keep the filename free of separators
+	target := strings.Replace(phoneNumber, "https://whatsapp.com/channel/", "channel_", 1)
+	target = strings.NewReplacer("@", "_", ":", "_", "/", "_").Replace(target)
+	return fmt.Sprintf("profile_%s_%s.jpg", target, fetchedAt.Format("20060102_150405"))
+}
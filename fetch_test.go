@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go-web-wa/pkg/batch"
+	"go-web-wa/pkg/clock"
+	"go-web-wa/pkg/config"
+	"go-web-wa/pkg/discord"
+	"go-web-wa/pkg/state"
+	"go-web-wa/pkg/whatsapp"
+)
+
+func TestProfileFilename(t *testing.T) {
+	// 23:30 UTC is already the next day in TZ=Asia/Jakarta
+	fake := clock.NewFake(time.Date(2025, 3, 1, 23, 30, 5, 0, time.UTC))
+	clk := clock.InLocation{Clock: fake, Location: time.FixedZone("WIB", 7*60*60)}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"+1234567890", "profile_+1234567890_20250302_063005.jpg"},
+		{"1234567890@s.whatsapp.net", "profile_1234567890_s.whatsapp.net_20250302_063005.jpg"},
+		{"1234567890:12@s.whatsapp.net", "profile_1234567890_12_s.whatsapp.net_20250302_063005.jpg"},
+		{"https://whatsapp.com/channel/AbCdEf", "profile_channel_AbCdEf_20250302_063005.jpg"},
+	}
+	for _, tt := range tests {
+		if got := profileFilename(tt.target, clk.Now()); got != tt.want {
+			t.Errorf("profileFilename(%q) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+
+	fake.Advance(time.Minute)
+	if got, want := profileFilename("+1234567890", clk.Now()), "profile_+1234567890_20250302_063105.jpg"; got != want {
+		t.Errorf("after a minute profileFilename = %q, want %q", got, want)
+	}
+}
+
+func TestFetchNumbersReportsProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	stateStore, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	numbers := []string{"+1111111111", "+2222222222", "+3333333333"}
+	// Already sent this cycle, so fetching them succeeds without WhatsApp
+	for _, number := range numbers[:2] {
+		if err := stateStore.UpdateNumber(number, func(ns *state.NumberState) { ns.LastNotified = clk.Now() }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []batch.Progress
+	f := &fetcher{
+		cfg:     &config.Config{FetchConcurrency: 1, FetchRetryAttempts: 1, DiscordWebhookURL: server.URL},
+		discord: discord.NewWebhookClient(server.URL),
+		state:   stateStore,
+		clock:   clk,
+		progress: func(p batch.Progress) {
+			got = append(got, p)
+		},
+	}
+
+	f.fetchNumbers(context.Background(), numbers[:2], 0, clk.Now())
+	want := []batch.Progress{
+		{Number: numbers[0], Done: 1, Total: 2, Succeeded: 1},
+		{Number: numbers[1], Done: 2, Total: 2, Succeeded: 2},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("progress = %+v, want %+v", got, want)
+	}
+
+	// A cancelled run fails every remaining target
+	got = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.fetchNumbers(ctx, numbers[2:], 0, clk.Now())
+	want = []batch.Progress{{Number: numbers[2], Done: 1, Total: 1, Failed: 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("progress of a cancelled run = %+v, want %+v", got, want)
+	}
+}
+
+func TestFetchNumbersSpreadFollowsClock(t *testing.T) {
+	stateStore, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	numbers := []string{"+1111111111", "+2222222222"}
+	// Already sent this cycle, so fetching them succeeds without WhatsApp
+	for _, number := range numbers {
+		if err := stateStore.UpdateNumber(number, func(ns *state.NumberState) { ns.LastNotified = clk.Now() }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan string, len(numbers))
+	f := &fetcher{
+		cfg:   &config.Config{FetchConcurrency: 1, FetchRetryAttempts: 1},
+		state: stateStore,
+		clock: clk,
+		progress: func(p batch.Progress) {
+			done <- p.Number
+		},
+	}
+	go f.fetchNumbers(context.Background(), numbers, time.Minute, clk.Now())
+
+	// The second target starts half way through the window on the fetcher's clock
+	if got := <-done; got != numbers[0] {
+		t.Fatalf("first finished target = %s, want %s", got, numbers[0])
+	}
+	select {
+	case got := <-done:
+		t.Fatalf("%s finished before the clock reached its slot", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	clk.Advance(30 * time.Second)
+	select {
+	case got := <-done:
+		if got != numbers[1] {
+			t.Errorf("second finished target = %s, want %s", got, numbers[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second target didn't start after the clock reached its slot")
+	}
+}
+
+func TestFlushGalleryRecordsDeliveryOnSuccess(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	stateStore, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	f := &fetcher{
+		cfg:     &config.Config{DiscordWebhookURL: server.URL},
+		discord: discord.NewWebhookClient(server.URL),
+		state:   stateStore,
+		clock:   clk,
+	}
+	queue := func() {
+		f.gallery = append(f.gallery, galleryEntry{
+			image:   discord.GalleryImage{Filename: "profile.jpg", Data: []byte("image"), Label: "+1234567890"},
+			number:  "+1234567890",
+			changed: true,
+		})
+	}
+
+	// A failed gallery leaves the picture undelivered for the next run
+	queue()
+	f.flushGallery()
+	if got := stateStore.Number("+1234567890"); !got.LastNotified.IsZero() || !got.ChangeNotified.IsZero() {
+		t.Errorf("after a failed gallery LastNotified = %v, ChangeNotified = %v, want both zero", got.LastNotified, got.ChangeNotified)
+	}
+
+	status = http.StatusNoContent
+	queue()
+	f.flushGallery()
+	if got := stateStore.Number("+1234567890"); !got.LastNotified.Equal(clk.Now()) || !got.ChangeNotified.Equal(clk.Now()) {
+		t.Errorf("after a sent gallery LastNotified = %v, ChangeNotified = %v, want %v", got.LastNotified, got.ChangeNotified, clk.Now())
+	}
+}
+
+func TestFlushGalleryPostsOneGalleryPerWebhook(t *testing.T) {
+	var mainRequests, routedRequests int
+	mainServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mainRequests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer mainServer.Close()
+	// The routed webhook rejects its gallery
+	routedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routedRequests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer routedServer.Close()
+
+	stateStore, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	f := &fetcher{
+		cfg: &config.Config{
+			DiscordWebhookURL: mainServer.URL,
+			TargetWebhooks:    map[string]string{"2222222222": routedServer.URL},
+		},
+		discord: discord.NewWebhookClient(mainServer.URL),
+		routed:  map[string]*discord.WebhookClient{routedServer.URL: discord.NewWebhookClient(routedServer.URL)},
+		state:   stateStore,
+		clock:   clk,
+	}
+	for _, number := range []string{"+1111111111", "+2222222222", "+3333333333"} {
+		f.gallery = append(f.gallery, galleryEntry{
+			image:  discord.GalleryImage{Filename: "profile.jpg", Data: []byte("image"), Label: number},
+			number: number,
+		})
+	}
+
+	f.flushGallery()
+
+	// The routed webhook also gets the error about its failed gallery
+	if mainRequests != 1 || routedRequests != 2 {
+		t.Errorf("requests = %d to the main webhook and %d to the routed one, want 1 and 2", mainRequests, routedRequests)
+	}
+	for number, wantSent := range map[string]bool{"+1111111111": true, "+2222222222": false, "+3333333333": true} {
+		if sent := !stateStore.Number(number).LastNotified.IsZero(); sent != wantSent {
+			t.Errorf("%s recorded as delivered = %v, want %v", number, sent, wantSent)
+		}
+	}
+}
+
+// blockingPublisher holds every publish until release is closed
+type blockingPublisher struct {
+	release   chan struct{}
+	published chan string
+}
+
+func (p *blockingPublisher) Publish(ctx context.Context, subject string, payload []byte) error {
+	select {
+	case <-p.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	p.published <- subject
+	return nil
+}
+
+func (p *blockingPublisher) Close() error { return nil }
+
+func TestPublishChangeDoesNotBlock(t *testing.T) {
+	pub := &blockingPublisher{release: make(chan struct{}), published: make(chan string, 1)}
+	f := &fetcher{
+		cfg:       &config.Config{PublisherSubject: "pictures"},
+		clock:     clock.NewFake(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)),
+		publisher: pub,
+	}
+
+	item := &batch.FetchItem{Number: "+1234567890"}
+	f.publishChange(context.Background(), item, &whatsapp.ProfilePicture{Data: []byte("image"), ID: "1"}, "new", "old")
+
+	// publishChange has returned although the bus hasn't answered yet
+	close(pub.release)
+	f.publishes.Wait()
+	if got := <-pub.published; got != "pictures" {
+		t.Errorf("published on %q, want pictures", got)
+	}
+}
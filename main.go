@@ -1,108 +1,536 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"go-web-wa/pkg/clock"
 	"go-web-wa/pkg/config"
 	"go-web-wa/pkg/discord"
+	"go-web-wa/pkg/logging"
+	"go-web-wa/pkg/mask"
+	"go-web-wa/pkg/netproxy"
+	"go-web-wa/pkg/publisher"
+	"go-web-wa/pkg/state"
+	"go-web-wa/pkg/storage"
 	"go-web-wa/pkg/whatsapp"
+
+	// Embed the time zone database so TIMEZONE works in minimal containers
+	_ "time/tzdata"
+)
+
+// Exit codes reported to cron/systemd
+const (
+	exitSuccess        = 0 // every target was fetched and sent
+	exitPartialFailure = 1 // at least one target (or the connection) failed
+	exitConfigError    = 2 // invalid configuration or command line
+	exitAuthRequired   = 3 // the session isn't paired; run the pair command first
+	exitLoggedOut      = 4 // WhatsApp logged the session out while running; pair again
 )
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command line and returns the process exit code
+func run(args []string) int {
+	// Logging is set up before anything else so every command honours it;
+	// bad values are reported by config validation as well. Records go through
+	// stderr so they don't run into the progress line of a single run.
+	stderr := &progressWriter{w: os.Stderr}
+	if err := logging.Setup(stderr, config.LogFormat(), config.LogLevel()); err != nil {
+		log.Printf("Failed to set up logging: %v", err)
+		return exitConfigError
+	}
+	// Until the targets are known, mask the numbers that are recognizable as such
+	if config.MaskPhoneNumbers() {
+		logging.SetMasker(mask.New())
+	}
+
+	// Dispatch subcommands
+	if len(args) > 0 {
+		switch args[0] {
+		case "pair":
+			return pairDevice(args[1:])
+		case "resend":
+			return resendLastImage()
+		case "export-state":
+			return exportState(args[1:])
+		case "history":
+			return showHistory(args[1:])
+		case "validate-config":
+			return validateConfig(args[1:])
+		case "devices":
+			return listDevices()
+		case "unlink-device":
+			return unlinkDevice(args[1:])
+		case "tail":
+			return tailEvents()
+		case "test-notify":
+			return testNotify()
+		case "version":
+			return printVersion()
+		case "fetch":
+			// Same as the default command, but accepts targets as arguments
+			args = args[1:]
+		}
+	}
+
+	// Parse run mode flags; any positional arguments are targets overriding TARGET_PHONE_NUMBER
+	flags := flag.NewFlagSet("go-web-wa", flag.ContinueOnError)
+	once := flags.Bool("once", false, "fetch every target once and exit (default)")
+	watch := flags.Bool("watch", false, "keep running and fetch every target on POLL_INTERVAL_SECONDS")
+	serve := flags.Bool("serve", false, "keep running and answer POST /fetch on API_LISTEN_ADDR")
+	showVersion := flags.Bool("version", false, "print the version and exit")
+	noProgress := flags.Bool("no-progress", false, "don't draw a progress line on stderr during a single run")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitConfigError
+	}
+	if *showVersion {
+		return printVersion()
+	}
+
+	if *once && (*watch || *serve) {
+		log.Printf("--once can't be combined with --watch or --serve")
+		return exitConfigError
+	}
+
+	// A "-" target reads newline-separated targets from stdin
+	targets, err := expandStdinTargets(flags.Args(), os.Stdin)
+	if err != nil {
+		log.Printf("Failed to read targets from stdin: %v", err)
+		return exitConfigError
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadWithTargets(targets)
+	if err != nil {
+		logConfigErrors(err)
+		return exitConfigError
+	}
+
+	if *serve && cfg.APIListenAddr == "" {
+		log.Printf("--serve requires API_LISTEN_ADDR")
+		return exitConfigError
+	}
+
+	masker := setupMasking(cfg)
+
+	log.Printf("Starting WhatsApp Profile Fetcher for: %s", strings.Join(cfg.TargetPhoneNumbers, ", "))
+
+	// Reuse the daemon's warm connection instead of connecting for a single run
+	if cfg.DaemonURL != "" && !*watch && !*serve {
+		if exitCode, ok := runOnDaemon(cfg); ok {
+			return exitCode
+		}
+	}
+
+	// Render embed timestamps, filenames and log lines in the configured time zone
+	logging.SetLocation(cfg.Location)
+	clk := clock.InLocation{Clock: clock.Real{}, Location: cfg.Location}
+
+	// Validate the embed templates before doing any work
+	templates, err := discord.NewImageTemplates(cfg.TitleTemplate, cfg.DescriptionTemplate)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		log.Printf("Invalid embed template: %v", err)
+		return exitConfigError
+	}
+
+	// Every outgoing HTTP call goes through PROXY_URL when it is set and trusts EXTRA_CA_CERTS
+	rootCAs, err := netproxy.LoadCertPool(cfg.ExtraCACerts)
+	if err != nil {
+		log.Printf("Failed to load EXTRA_CA_CERTS: %v", err)
+		return exitConfigError
+	}
+	httpClient, err := netproxy.NewHTTPClient(cfg.ProxyURL, 30*time.Second, rootCAs)
+	if err != nil {
+		log.Printf("Failed to configure proxy: %v", err)
+		return exitConfigError
+	}
+
+	// Stop cleanly on Ctrl+C or when the service manager asks us to
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Bound a single run so a hang can't hold a cron slot forever
+	runBounded := !*watch && !*serve && cfg.MaxRunDuration > 0
+	if runBounded {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeout(ctx, cfg.MaxRunDuration)
+		defer cancelDeadline()
 	}
 
-	log.Printf("Starting WhatsApp Profile Fetcher for: %s", cfg.TargetPhoneNumber)
+	// Initialize Discord clients: the default webhook and one per routed webhook.
+	// Retries give up on shutdown and at the run deadline.
+	newDiscordClient := func(webhookURL string) *discord.WebhookClient {
+		return discord.NewWebhookClient(webhookURL,
+			discord.WithClock(clk),
+			discord.WithHTTPClient(httpClient),
+			discord.WithEncoder(webhookEncoder(cfg)),
+			discord.WithImageTemplates(templates),
+			discord.WithInsecureSkipVerify(cfg.InsecureSkipVerify),
+			discord.WithRetryAttempts(cfg.WebhookRetries),
+			discord.WithContext(ctx),
+			discord.WithMasker(masker),
+			discord.WithVersion(buildVersion()),
+		)
+	}
+	discordClient := newDiscordClient(cfg.DiscordWebhookURL)
+	routedClients := make(map[string]*discord.WebhookClient)
+	for _, webhookURL := range cfg.TargetWebhooks {
+		if _, ok := routedClients[webhookURL]; !ok && webhookURL != cfg.DiscordWebhookURL {
+			routedClients[webhookURL] = newDiscordClient(webhookURL)
+		}
+	}
 
-	// Initialize Discord client
-	discordClient := discord.NewWebhookClient(cfg.DiscordWebhookURL)
+	// Open the change-detection state
+	stateStore, err := state.Open(cfg.StateFilePath)
+	if err != nil {
+		log.Printf("Failed to open state store: %v", err)
+		sendErrorToDiscord(discordClient, "State Error", fmt.Sprintf("Failed to open state store: %v", err))
+		return exitPartialFailure
+	}
 
 	// Initialize WhatsApp client
-	waClient, err := whatsapp.NewClient(cfg.SessionFilePath)
+	var readinessChecks []whatsapp.ReadinessCheck
+	for _, check := range cfg.ReadyChecks {
+		// Being connected is always required
+		if check != "connected" {
+			readinessChecks = append(readinessChecks, whatsapp.ReadinessCheck(check))
+		}
+	}
+	waOpts := []whatsapp.Option{
+		whatsapp.WithReadinessChecks(readinessChecks...),
+		whatsapp.WithProfileInfoTimeout(cfg.ProfileInfoTimeout),
+		whatsapp.WithConnectPollInterval(cfg.ConnectPollInterval),
+		whatsapp.WithUserAgent(cfg.DownloadUserAgent),
+		whatsapp.WithDownloadTimeouts(whatsapp.DownloadTimeouts{
+			Dial:           cfg.DownloadDialTimeout,
+			TLSHandshake:   cfg.DownloadTLSTimeout,
+			ResponseHeader: cfg.DownloadHeaderTimeout,
+			Overall:        cfg.DownloadTimeout,
+		}),
+		whatsapp.WithProfileCache(cfg.ProfileCacheTTL),
+		whatsapp.WithDefaultCountryCode(cfg.DefaultCountryCode),
+		whatsapp.WithUserServer(cfg.JIDServer),
+		whatsapp.WithFetchConcurrency(cfg.FetchConcurrency),
+		whatsapp.WithDownloadConcurrency(cfg.DownloadLimit),
+		whatsapp.WithNonContactRetry(cfg.NonContactRetry),
+		whatsapp.WithProxy(cfg.ProxyURL),
+		whatsapp.WithRootCAs(rootCAs),
+		whatsapp.WithSessionEncryption(cfg.SessionEncryptionKey, cfg.SessionEncryptionPreviousKey),
+		whatsapp.WithDatabasePath(cfg.DatabasePath),
+		whatsapp.WithSQLiteOptions(whatsapp.SQLiteOptions{
+			BusyTimeout: cfg.SQLiteBusyTimeout,
+			JournalMode: cfg.SQLiteJournalMode,
+			Synchronous: cfg.SQLiteSynchronous,
+		}),
+	}
+	if cfg.IgnoreDefaultAvatars {
+		waOpts = append(waOpts, whatsapp.WithDefaultAvatarHashes(cfg.DefaultAvatarHashes...))
+	}
+	if cfg.ConditionalDownloads {
+		waOpts = append(waOpts, whatsapp.WithConditionalDownloads(stateStore))
+	}
+
+	waClient, err := whatsapp.NewClient(cfg.SessionFilePath, waOpts...)
 	if err != nil {
 		log.Printf("Failed to create WhatsApp client: %v", err)
 		sendErrorToDiscord(discordClient, "WhatsApp Client Error", fmt.Sprintf("Failed to create WhatsApp client: %v", err))
-		return
+		return exitPartialFailure
 	}
 	defer waClient.Close()
+	if runBounded {
+		go abortHungRun(ctx, discordClient, waClient, cfg.MaxRunDuration)
+	}
 
 	// Check if paired/logged in
 	if !waClient.IsLoggedIn() {
 		log.Printf("WhatsApp client not logged in. Please run the pairing process first.")
 		sendErrorToDiscord(discordClient, "Authentication Required", "WhatsApp client not logged in. Please run the pairing process first.")
-		return
+		return exitAuthRequired
+	}
+
+	waClient.OnStateChange(func(state whatsapp.ConnectionState) {
+		log.Printf("WhatsApp connection state: %s", state)
+	})
+
+	// A forced logout can't be retried away: stop fetching and ask for re-pairing
+	ctx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	var logoutReason atomic.Pointer[string]
+	waClient.OnLoggedOut(func(reason string) {
+		logoutReason.Store(&reason)
+		cancelRun()
+	})
+	reportLoggedOut := func() int {
+		reason := *logoutReason.Load()
+		log.Printf("Session was logged out by WhatsApp (%s); run the pair command again", reason)
+		sendErrorToDiscord(discordClient, "Re-pairing Required", fmt.Sprintf("WhatsApp logged this session out (%s). Run the pair command again to continue.", reason))
+		return exitLoggedOut
 	}
 
-	// Connect to WhatsApp
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	// Connect to WhatsApp, riding out transient network failures within an overall deadline
+	connectCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 
 	log.Println("Connecting to WhatsApp...")
-	if err := waClient.Connect(ctx); err != nil {
+	if err := waClient.ConnectWithRetry(connectCtx, cfg.ConnectRetryAttempts); err != nil {
+		if logoutReason.Load() != nil {
+			return reportLoggedOut()
+		}
 		log.Printf("Failed to connect to WhatsApp: %v", err)
 		sendErrorToDiscord(discordClient, "Connection Error", fmt.Sprintf("Failed to connect to WhatsApp: %v", err))
-		return
+		return exitPartialFailure
 	}
 
-	// Wait a moment for connection to stabilize
-	time.Sleep(2 * time.Second)
+	// Wait until the session is confirmed and the READY_CHECKS pass before fetching
+	readyCtx, cancelReady := context.WithTimeout(ctx, cfg.ConnectStabilizeTimeout+cfg.AppStateSyncTimeout)
+	err = waClient.WaitUntilReady(readyCtx)
+	cancelReady()
+	if err != nil {
+		if logoutReason.Load() != nil {
+			return reportLoggedOut()
+		}
+		log.Printf("WhatsApp client did not become ready: %v", err)
+		sendErrorToDiscord(discordClient, "Connection Error", fmt.Sprintf("WhatsApp client did not become ready: %v", err))
+		return exitPartialFailure
+	}
 
 	// Test network connectivity first
 	log.Println("Testing network connectivity...")
-	if err := testNetworkConnectivity(); err != nil {
+	if err := testNetworkConnectivity(httpClient); err != nil {
 		log.Printf("Network connectivity test failed: %v", err)
 		sendErrorToDiscord(discordClient, "Network Error", fmt.Sprintf("Network connectivity test failed: %v", err))
 		// Continue anyway - it might still work
 	}
 
-	// Fetch profile picture
-	log.Printf("Fetching profile picture for: %s", cfg.TargetPhoneNumber)
-	imageData, err := waClient.GetProfilePicture(cfg.TargetPhoneNumber)
+	f := &fetcher{
+		cfg:        cfg,
+		wa:         waClient,
+		discord:    discordClient,
+		state:      stateStore,
+		clock:      clk,
+		routed:     routedClients,
+		httpClient: httpClient,
+
+		backoffFailures: *watch && cfg.FailureBackoff > 0,
+	}
+
+	// Draw progress for single runs in a terminal; it would only clutter
+	// JSON logs, redirected output and the long-running modes
+	if !*watch && !*serve && !*noProgress && cfg.LogFormat != "json" && isTerminal(os.Stderr) {
+		f.progress = stderr.reporter(masker)
+	}
+
+	// Archive fetched images when storage is configured
+	f.storage, err = newStorageBackend(cfg, httpClient)
 	if err != nil {
-		log.Printf("Failed to fetch profile picture: %v", err)
-		sendErrorToDiscord(discordClient, "Profile Picture Error", fmt.Sprintf("Failed to fetch profile picture for %s: %v", cfg.TargetPhoneNumber, err))
-		return
+		log.Printf("Failed to configure storage: %v", err)
+		return exitConfigError
 	}
 
-	fmt.Println("Successfully fetched profile picture")
+	// Announce changes on the message bus as well when one is configured
+	if cfg.Publisher != "" {
+		pub, err := publisher.New(cfg.Publisher, cfg.PublisherURL)
+		if err != nil {
+			log.Printf("Failed to configure publisher: %v", err)
+			return exitConfigError
+		}
+		defer func() {
+			f.publishes.Wait()
+			pub.Close()
+		}()
+		f.publisher = pub
+	}
 
-	// Generate filename
-	filename := fmt.Sprintf("profile_%s_%s.jpg", cfg.TargetPhoneNumber, time.Now().Format("20060102_150405"))
+	// Keep a long-running session active so WhatsApp doesn't unlink it
+	if (*watch || *serve) && cfg.KeepaliveInterval > 0 {
+		go waClient.KeepAlive(ctx, cfg.KeepaliveInterval)
+	}
 
-	// Send image to Discord
-	log.Println("Sending profile picture to Discord...")
-	if err := discordClient.SendImageWithFile(imageData, filename, cfg.TargetPhoneNumber); err != nil {
-		log.Printf("Failed to send image to Discord: %v", err)
-		sendErrorToDiscord(discordClient, "Discord Error", fmt.Sprintf("Failed to send image to Discord: %v", err))
-		return
+	// Answer on-demand fetches over the same connection, alongside --watch if set
+	var apiDone chan error
+	if *serve {
+		listener, err := net.Listen("tcp", cfg.APIListenAddr)
+		if err != nil {
+			log.Printf("Failed to listen on %s: %v", cfg.APIListenAddr, err)
+			return exitPartialFailure
+		}
+		apiDone = make(chan error, 1)
+		go func() {
+			apiDone <- f.serveAPI(ctx, listener)
+			// A failed server stops the run rather than leaving it half working
+			cancelRun()
+		}()
 	}
 
-	// Send success message
-	log.Println("Profile picture sent successfully!")
-	discordClient.SendSuccessMessage(
-		"Profile Picture Fetched",
-		fmt.Sprintf("Successfully fetched and sent profile picture for %s", cfg.TargetPhoneNumber),
-	)
+	daemon := *watch || *serve
+	if daemon && cfg.Heartbeat {
+		sendHeartbeat(discordClient, true, len(cfg.TargetPhoneNumbers))
+	}
 
-	// Wait a moment for the message to be sent
-	time.Sleep(2 * time.Second)
+	var exitCode int
+	switch {
+	case *serve && !*watch:
+		exitCode = exitSuccess
+		<-ctx.Done()
+	case *watch && cfg.FetchOnOnline:
+		exitCode = f.watchPresence(ctx)
+	case *watch:
+		exitCode = f.watch(ctx)
+	default:
+		exitCode = exitSuccess
+		if err := f.openArchive(); err != nil {
+			log.Printf("Failed to start run archive: %v", err)
+		}
+		if result := f.fetchTargets(ctx, 0, time.Time{}); len(result.Failed()) > 0 {
+			log.Printf("%d of %d targets failed", len(result.Failed()), len(result.Items))
+			logErrorGroups(result)
+			exitCode = exitPartialFailure
+		}
+		f.closeArchive()
+	}
+
+	if apiDone != nil {
+		cancelRun()
+		if err := <-apiDone; err != nil {
+			log.Printf("API server failed: %v", err)
+			exitCode = exitPartialFailure
+		}
+	}
 
 	// Disconnect from WhatsApp
 	waClient.Disconnect()
 
-	// Wait a moment for the message to be sent
-	time.Sleep(2 * time.Second)
+	if logoutReason.Load() != nil {
+		return reportLoggedOut()
+	}
+
+	if daemon && cfg.Heartbeat && exitCode == exitSuccess {
+		sendHeartbeat(discordClient, false, len(cfg.TargetPhoneNumbers))
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("Run exceeded MAX_RUN_SECONDS (%s) and was aborted", cfg.MaxRunDuration)
+		sendErrorToDiscord(discordClient, "Run Timed Out", fmt.Sprintf("The run took longer than MAX_RUN_SECONDS (%s) and was aborted.", cfg.MaxRunDuration))
+		return exitPartialFailure
+	}
 
-	log.Println("Task completed successfully!")
+	if exitCode == exitSuccess {
+		log.Println("Task completed successfully!")
+	}
+	return exitCode
+}
+
+// expandStdinTargets replaces a "-" argument with the targets read from r, one
+// per line; blank lines and lines starting with # are ignored
+func expandStdinTargets(args []string, r io.Reader) ([]string, error) {
+	if !slices.Contains(args, "-") {
+		return args, nil
+	}
+
+	var targets []string
+	for _, arg := range args {
+		if arg != "-" {
+			targets = append(targets, arg)
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no targets given")
+	}
+	return targets, nil
+}
+
+// setupMasking masks the targets and other phone numbers in log lines when
+// MASK_PHONE_NUMBERS is set and returns the masker for Discord clients, nil
+// when masking is off
+func setupMasking(cfg *config.Config) *mask.Masker {
+	if !cfg.MaskPhoneNumbers {
+		return nil
+	}
+	m := mask.New(cfg.TargetPhoneNumbers...)
+	logging.SetMasker(m)
+	return m
+}
+
+// abortHungRunGrace is how long a run may keep going after MAX_RUN_SECONDS
+// before abortHungRun stops the process
+const abortHungRunGrace = 30 * time.Second
+
+// abortHungRun exits the process when a run is still going abortHungRunGrace
+// after its deadline, for calls that don't honour the cancelled context. It
+// closes waClient first so an encrypted session is sealed again.
+func abortHungRun(ctx context.Context, client *discord.WebhookClient, waClient *whatsapp.Client, limit time.Duration) {
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	time.Sleep(abortHungRunGrace)
+	log.Printf("Run still hung %s after MAX_RUN_SECONDS (%s); exiting", abortHungRunGrace, limit)
+	sendErrorToDiscord(client, "Run Timed Out", fmt.Sprintf("The run took longer than MAX_RUN_SECONDS (%s) and didn't stop; the process was terminated.", limit))
+	if err := waClient.Close(); err != nil {
+		log.Printf("Failed to close WhatsApp client: %v", err)
+	}
+	os.Exit(exitPartialFailure)
+}
+
+// newStorageBackend returns the backend selected by STORAGE_BACKEND, or nil
+// when local storage has no STORAGE_DIR
+func newStorageBackend(cfg *config.Config, httpClient *http.Client) (storage.Backend, error) {
+	if cfg.StorageBackend == "s3" {
+		return storage.NewS3Backend(storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			URLExpiry:       cfg.SignedURLExpiry,
+			ClockSkew:       cfg.SignedURLClockSkew,
+			HTTPClient:      httpClient,
+		})
+	}
+	if cfg.StorageDir == "" {
+		return nil, nil
+	}
+	return storage.NewLocalBackend(cfg.StorageDir, cfg.StorageBaseURL)
+}
+
+// webhookEncoder returns the payload encoder selected by WEBHOOK_ENCODING
+func webhookEncoder(cfg *config.Config) discord.Encoder {
+	if cfg.WebhookEncoding == "form" {
+		return discord.FormEncoder{}
+	}
+	return discord.JSONEncoder{}
 }
 
 // sendErrorToDiscord sends an error message to Discord
@@ -112,25 +540,103 @@ func sendErrorToDiscord(client *discord.WebhookClient, title, message string) {
 	}
 }
 
-// pairDevice handles the initial pairing process
-func pairDevice() {
+// sendHeartbeat posts the online or offline heartbeat, logging failures
+func sendHeartbeat(client *discord.WebhookClient, online bool, tracked int) {
+	if err := client.SendHeartbeat(online, tracked); err != nil {
+		log.Printf("Failed to send heartbeat to Discord: %v", err)
+	}
+}
+
+// pairDevice handles the initial pairing process and returns the exit code
+func pairDevice(args []string) int {
+	qrStyle, qrLevel, qrPNGPath := config.QRSettings()
+	flags := flag.NewFlagSet("pair", flag.ContinueOnError)
+	flags.StringVar(&qrStyle, "qr-style", qrStyle, "how to show QR codes: half-block, full-block or png")
+	flags.StringVar(&qrLevel, "qr-level", qrLevel, "QR error correction level: L, M, Q or H")
+	flags.StringVar(&qrPNGPath, "qr-png", qrPNGPath, "file the png QR style writes to")
+	web := flags.Bool("web", false, "serve a pairing page on PAIR_WEB_ADDR instead of asking in the terminal")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
+		}
+		return exitConfigError
+	}
+
 	// Load configuration
 	sessionPath := os.Getenv("SESSION_FILE_PATH")
 	if sessionPath == "" {
 		sessionPath = "./sessions/"
 	}
 
+	secrets, err := readSecrets("SESSION_ENCRYPTION_KEY", "PROXY_URL", "DISCORD_WEBHOOK_URL", "PAIR_WEB_TOKEN")
+	if err != nil {
+		log.Printf("Failed to read secret: %v", err)
+		return exitConfigError
+	}
+	proxyURL := secrets[1]
+	if *web && secrets[3] == "" {
+		log.Println("PAIR_WEB_TOKEN is required with --web")
+		return exitConfigError
+	}
+	rootCAs, err := netproxy.LoadCertPool(os.Getenv("EXTRA_CA_CERTS"))
+	if err != nil {
+		log.Printf("Failed to load EXTRA_CA_CERTS: %v", err)
+		return exitConfigError
+	}
+	opts := []whatsapp.Option{
+		whatsapp.WithSessionEncryption(secrets[0]),
+		whatsapp.WithProxy(proxyURL),
+		whatsapp.WithRootCAs(rootCAs),
+		whatsapp.WithDatabasePath(config.DatabasePath()),
+		whatsapp.WithQROptions(whatsapp.QROptions{
+			Style:   whatsapp.QRStyle(strings.ToLower(qrStyle)),
+			Level:   qrLevel,
+			PNGPath: qrPNGPath,
+		}),
+	}
+
+	// Mirror QR codes to Discord so headless servers can pair without a terminal
+	if webhookURL := secrets[2]; webhookURL != "" {
+		httpClient, err := netproxy.NewHTTPClient(proxyURL, 30*time.Second, rootCAs)
+		if err != nil {
+			log.Printf("Failed to configure proxy: %v", err)
+			return exitConfigError
+		}
+		opts = append(opts, whatsapp.WithQRHandler(discordQRHandler(discord.NewWebhookClient(webhookURL, discord.WithHTTPClient(httpClient)))))
+	}
+
 	// Initialize WhatsApp client
-	waClient, err := whatsapp.NewClient(sessionPath)
+	waClient, err := whatsapp.NewClient(sessionPath, opts...)
 	if err != nil {
-		log.Fatalf("Failed to create WhatsApp client: %v", err)
+		log.Printf("Failed to create WhatsApp client: %v", err)
+		return exitPartialFailure
 	}
-	defer waClient.Close()
+	// Returning instead of exiting lets Close encrypt the session database again
+	defer func() {
+		if err := waClient.Close(); err != nil {
+			log.Printf("Failed to close WhatsApp client: %v", err)
+		}
+	}()
 
 	// Check if already paired
 	if waClient.IsLoggedIn() {
 		log.Println("Already logged in to WhatsApp")
-		return
+		return exitSuccess
+	}
+
+	if *web {
+		// Like terminal pairing, the page can be aborted with Ctrl+C and gives up after five minutes
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		defer cancel()
+
+		if err := pairWeb(ctx, waClient, config.PairWebAddr(), secrets[3]); err != nil {
+			log.Printf("Failed to pair from the web page: %v", err)
+			return exitPartialFailure
+		}
+		log.Println("Pairing completed successfully!")
+		return exitSuccess
 	}
 
 	// Ask for pairing method
@@ -142,49 +648,76 @@ func pairDevice() {
 	var choice string
 	fmt.Scanln(&choice)
 
+	// Pairing can be aborted with Ctrl+C and gives up after five minutes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
 	switch choice {
 	case "1":
 		log.Println("Starting QR code pairing...")
-		if err := waClient.PairQR(); err != nil {
-			log.Fatalf("Failed to pair with QR code: %v", err)
+		if err := waClient.PairQR(ctx); err != nil {
+			log.Printf("Failed to pair with QR code: %v", err)
+			return exitPartialFailure
 		}
 	case "2":
 		fmt.Print("Enter your phone number (with country code, e.g., +1234567890): ")
 		var phoneNumber string
 		fmt.Scanln(&phoneNumber)
 
-		// Clean phone number
-		phoneNumber = strings.ReplaceAll(phoneNumber, "+", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
-		phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
+		phoneNumber = cleanPhoneNumber(phoneNumber)
 
 		log.Printf("Starting phone number pairing for: %s", phoneNumber)
-		if err := waClient.PairPhone(phoneNumber); err != nil {
-			log.Fatalf("Failed to pair with phone number: %v", err)
+		if err := waClient.PairPhone(ctx, phoneNumber); err != nil {
+			log.Printf("Failed to pair with phone number: %v", err)
+			return exitPartialFailure
 		}
 	default:
-		log.Fatalf("Invalid choice: %s", choice)
+		log.Printf("Invalid choice: %s", choice)
+		return exitConfigError
 	}
 
 	log.Println("Pairing completed successfully!")
+	return exitSuccess
 }
 
-// init function to check command line arguments
-func init() {
-	if len(os.Args) > 1 && os.Args[1] == "pair" {
-		pairDevice()
-		os.Exit(0)
+// discordQRHandler posts the pairing QR code to Discord, editing the same
+// message each time the code rotates
+func discordQRHandler(client *discord.WebhookClient) func(code string) {
+	var messageID string
+	return func(code string) {
+		pngData, err := whatsapp.QRCodePNG(code)
+		if err != nil {
+			log.Printf("Failed to render QR code: %v", err)
+			return
+		}
+
+		if messageID != "" {
+			err := client.UpdateQRCode(messageID, pngData)
+			if err == nil {
+				return
+			}
+			log.Printf("Failed to update QR code on Discord, posting a new one: %v", err)
+		}
+
+		if messageID, err = client.SendQRCode(pngData); err != nil {
+			log.Printf("Failed to send QR code to Discord: %v", err)
+		}
 	}
 }
 
-// testNetworkConnectivity tests basic network connectivity
-func testNetworkConnectivity() error {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
+// testNetworkConnectivity tests basic network connectivity using client
+func testNetworkConnectivity(client *http.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	// Test with a reliable external service
-	resp, err := client.Get("https://www.google.com")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.google.com", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to reach external service: %w", err)
 	}
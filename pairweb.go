@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-web-wa/pkg/whatsapp"
+)
+
+// pairWebShutdownDelay keeps the pairing page up briefly after success so it
+// can show the result
+const pairWebShutdownDelay = 5 * time.Second
+
+// pairWeb serves a page on addr that shows the rotating QR code and accepts a
+// phone number for a pairing code, until pairing succeeds, fails or ctx is done
+func pairWeb(ctx context.Context, waClient *whatsapp.Client, addr, token string) error {
+	codes, err := waClient.PairQRChannel(ctx)
+	if err != nil {
+		return err
+	}
+
+	page := &pairPage{wa: waClient}
+	go func() {
+		for code := range codes {
+			page.setQR(code)
+		}
+		page.setQR("")
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", requirePairToken(token, page.handleIndex))
+	mux.HandleFunc("GET /qr.png", requirePairToken(token, page.handleQR))
+	mux.HandleFunc("GET /status", requirePairToken(token, page.handleStatus))
+	mux.HandleFunc("POST /code", requirePairToken(token, page.handleCode))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Serving pairing page on http://%s", listener.Addr())
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+		close(serveErr)
+	}()
+
+	pairErr := waClient.WaitForPairing(ctx)
+	page.setResult(pairErr)
+	if pairErr == nil {
+		// Let the page pick up the result before the server goes away
+		select {
+		case <-time.After(pairWebShutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down pairing page: %v", err)
+	}
+	if err := <-serveErr; err != nil {
+		return err
+	}
+	return pairErr
+}
+
+// requirePairToken rejects requests without the pairing token, given either as
+// a bearer token or as the password of HTTP basic auth so browsers can prompt for it
+func requirePairToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	expected := []byte(token)
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, given, _ = r.BasicAuth()
+		}
+		if subtle.ConstantTimeCompare([]byte(given), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pairing"`)
+			http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// pairPage holds what the pairing page shows
+type pairPage struct {
+	wa *whatsapp.Client
+
+	mu     sync.Mutex
+	qr     string
+	done   bool
+	result error
+}
+
+// pairStatus is the body of GET /status
+type pairStatus struct {
+	State  string `json:"state"`
+	QR     bool   `json:"qr"`
+	Paired bool   `json:"paired"`
+	Error  string `json:"error,omitempty"`
+}
+
+// setQR replaces the current QR code; an empty code means QR pairing ended
+func (p *pairPage) setQR(code string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.qr = code
+}
+
+// setResult records how pairing ended
+func (p *pairPage) setResult(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done = true
+	p.result = err
+}
+
+// handleIndex serves the pairing page
+func (p *pairPage) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write([]byte(pairPageHTML)); err != nil {
+		log.Printf("Failed to write pairing page: %v", err)
+	}
+}
+
+// handleQR renders the current QR code as a PNG
+func (p *pairPage) handleQR(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	code := p.qr
+	p.mu.Unlock()
+
+	if code == "" {
+		http.Error(w, "no QR code available", http.StatusNotFound)
+		return
+	}
+	pngData, err := whatsapp.QRCodePNG(code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(pngData); err != nil {
+		log.Printf("Failed to write QR code: %v", err)
+	}
+}
+
+// handleStatus reports the pairing progress the page polls for
+func (p *pairPage) handleStatus(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	status := pairStatus{
+		State:  p.wa.State().String(),
+		QR:     p.qr != "",
+		Paired: p.done && p.result == nil,
+	}
+	if p.done && p.result != nil {
+		status.Error = p.result.Error()
+	}
+	p.mu.Unlock()
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleCode requests a pairing code for the submitted phone number
+func (p *pairPage) handleCode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body: " + err.Error()})
+		return
+	}
+	phone := cleanPhoneNumber(req.Phone)
+	if phone == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "phone is required"})
+		return
+	}
+
+	log.Printf("Requesting pairing code for: %s", phone)
+	code, err := p.wa.RequestPairingCode(r.Context(), phone)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, apiError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"code": code})
+}
+
+// cleanPhoneNumber strips the +, dashes and spaces people type around a number
+func cleanPhoneNumber(phone string) string {
+	return strings.NewReplacer("+", "", "-", "", " ", "").Replace(strings.TrimSpace(phone))
+}
+
+// pairPageHTML polls /status, reloads the QR image as it rotates and asks
+// for a pairing code on submit
+const pairPageHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Pair WhatsApp</title>
+<style>
+body { font-family: sans-serif; max-width: 28rem; margin: 2rem auto; padding: 0 1rem; }
+img { width: 100%; image-rendering: pixelated; }
+#code { font-size: 2rem; letter-spacing: .2rem; }
+</style>
+</head>
+<body>
+<h1>Pair WhatsApp</h1>
+<p id="status">Waiting for QR code…</p>
+<img id="qr" alt="Pairing QR code" hidden>
+<p>Scan with WhatsApp → Linked devices, or link with a phone number instead:</p>
+<form id="form">
+<input id="phone" type="tel" placeholder="+1234567890" required>
+<button>Get pairing code</button>
+</form>
+<p id="code"></p>
+<script>
+const statusEl = document.getElementById("status");
+const qr = document.getElementById("qr");
+let finished = false;
+
+async function poll() {
+  try {
+    const res = await fetch("status");
+    const s = await res.json();
+    if (s.paired) {
+      statusEl.textContent = "Paired successfully. You can close this page.";
+    } else if (s.error) {
+      statusEl.textContent = "Pairing failed: " + s.error;
+    } else {
+      statusEl.textContent = s.qr ? "Scan the QR code (" + s.state + ")" : "No QR code available (" + s.state + ")";
+      if (s.qr) qr.src = "qr.png?t=" + Date.now();
+      qr.hidden = !s.qr;
+      return;
+    }
+    qr.hidden = true;
+    finished = true;
+  } catch (e) {
+    statusEl.textContent = "Pairing page is no longer available";
+    finished = true;
+  }
+}
+
+document.getElementById("form").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const out = document.getElementById("code");
+  out.textContent = "Requesting…";
+  const res = await fetch("code", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ phone: document.getElementById("phone").value }),
+  });
+  const body = await res.json();
+  out.textContent = res.ok ? body.code : "Error: " + body.error;
+});
+
+(async function loop() {
+  await poll();
+  if (!finished) setTimeout(loop, 2000);
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,169 @@
+package batch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FetchItem is the outcome of fetching a single target
+type FetchItem struct {
+	Number string
+	// Bytes is the size of the fetched image
+	Bytes int
+	// Changed is true when the image differs from the one seen on the previous fetch
+	Changed bool
+	// Skipped is true when the target was already handled earlier in this cycle
+	// or is backing off after repeated failures
+	Skipped bool
+	// Unchanged is true when WhatsApp confirmed the already posted picture is still current
+	Unchanged bool
+	// Suppressed is true when a new picture was stored but not posted because
+	// the previous change was posted less than CHANGE_COOLDOWN_SECONDS ago
+	Suppressed bool
+	// Removed is true when the contact removed the picture posted before
+	Removed bool
+	// Queued is true when the picture waits to be posted with the run's
+	// gallery; it only counts as delivered once the gallery is sent
+	Queued bool
+	Err    error
+	// Name is the contact's display name, empty when only the number is known
+	Name string
+	// Label is how posts refer to the target: Name, or the formatted number
+	// when no name is known. It is empty until the name has been looked up.
+	Label string
+	// ImageURL is where the fetched image was stored, if storage is enabled
+	ImageURL string
+	// Image is the fetched image, kept only when a result callback needs it
+	Image []byte
+	// CorrelationID tags the log lines of this target's fetch
+	CorrelationID string
+}
+
+// FetchResult summarizes a run over several targets
+type FetchResult struct {
+	Items    []FetchItem
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Progress reports how far a run over several targets has got
+type Progress struct {
+	// Number is the target that just finished
+	Number    string
+	Done      int
+	Total     int
+	Succeeded int
+	Failed    int
+}
+
+// Percent returns the share of targets done, from 0 to 100
+func (p Progress) Percent() int {
+	if p.Total == 0 {
+		return 100
+	}
+	return p.Done * 100 / p.Total
+}
+
+// ProgressFunc is called after every finished target of a run; calls never overlap
+type ProgressFunc func(Progress)
+
+// Failed returns the items that ended in an error
+func (r FetchResult) Failed() []FetchItem {
+	var failed []FetchItem
+	for _, item := range r.Items {
+		if item.Err != nil {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// ChangedCount returns how many targets have a new image
+func (r FetchResult) ChangedCount() int {
+	count := 0
+	for _, item := range r.Items {
+		if item.Changed {
+			count++
+		}
+	}
+	return count
+}
+
+// TotalBytes returns the combined size of all fetched images
+func (r FetchResult) TotalBytes() int {
+	total := 0
+	for _, item := range r.Items {
+		total += item.Bytes
+	}
+	return total
+}
+
+// FailedNumbers returns the numbers of the failed items
+func (r FetchResult) FailedNumbers() []string {
+	var numbers []string
+	for _, item := range r.Failed() {
+		numbers = append(numbers, item.Number)
+	}
+	return numbers
+}
+
+// ErrorGroup is a set of failed items that share the same error message
+type ErrorGroup struct {
+	// Message is the shared error with the item's own number removed
+	Message        string
+	Numbers        []string
+	CorrelationIDs []string
+	// Err is the error of the first item in the group
+	Err error
+}
+
+// Count returns how many items failed with this error
+func (g ErrorGroup) Count() int {
+	return len(g.Numbers)
+}
+
+// String summarizes the group, e.g. "12 numbers failed: not connected to WhatsApp"
+func (g ErrorGroup) String() string {
+	if g.Count() == 1 {
+		return "1 number failed: " + g.Message
+	}
+	return fmt.Sprintf("%d numbers failed: %s", g.Count(), g.Message)
+}
+
+// ErrorGroups groups the failed items by error message so one root cause is
+// reported once, most common first and otherwise in item order
+func (r FetchResult) ErrorGroups() []ErrorGroup {
+	var groups []ErrorGroup
+	index := make(map[string]int)
+	for _, item := range r.Failed() {
+		message := errorMessage(item)
+		i, ok := index[message]
+		if !ok {
+			i = len(groups)
+			index[message] = i
+			groups = append(groups, ErrorGroup{Message: message, Err: item.Err})
+		}
+		groups[i].Numbers = append(groups[i].Numbers, item.Number)
+		if item.CorrelationID != "" {
+			groups[i].CorrelationIDs = append(groups[i].CorrelationIDs, item.CorrelationID)
+		}
+	}
+
+	sort.SliceStable(groups, func(a, b int) bool {
+		return groups[a].Count() > groups[b].Count()
+	})
+	return groups
+}
+
+// errorMessage returns the item's error text without its own number, so the
+// same failure for different numbers compares equal
+func errorMessage(item FetchItem) string {
+	message := item.Err.Error()
+	if item.Number == "" {
+		return message
+	}
+	message = strings.ReplaceAll(message, " for "+item.Number, "")
+	return strings.ReplaceAll(message, item.Number, "<number>")
+}
@@ -0,0 +1,108 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers so both can be controlled in tests
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real is a Clock backed by the system time
+type Real struct{}
+
+// Now returns the current system time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for d on a system timer
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// InLocation wraps a Clock so the times it returns are in Location
+type InLocation struct {
+	Clock    Clock
+	Location *time.Location
+}
+
+// Now returns the wrapped clock's time converted to the location
+func (c InLocation) Now() time.Time {
+	return c.Clock.Now().In(c.Location)
+}
+
+// After waits for d on the wrapped clock
+func (c InLocation) After(d time.Duration) <-chan time.Time {
+	return c.Clock.After(d)
+}
+
+// Fake is a Clock that only moves when told to
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// fakeWaiter is a pending After call on a Fake
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFake creates a fake clock frozen at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake clock's current time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that fires once the fake clock has been moved d
+// past its current time, or right away when d isn't positive
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	f.fireLocked()
+	return ch
+}
+
+// Set moves the fake clock to now
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+	f.fireLocked()
+}
+
+// Advance moves the fake clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	f.fireLocked()
+}
+
+// fireLocked wakes the waiters whose time has come; the caller must hold f.mu
+func (f *Fake) fireLocked() {
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+}
@@ -0,0 +1,43 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAfter(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	select {
+	case <-f.After(0):
+	default:
+		t.Fatal("After(0) didn't fire right away")
+	}
+
+	ch := f.After(time.Minute)
+	f.Advance(59 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After(1m) fired after 59s")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case got := <-ch:
+		if want := start.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("After(1m) sent %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("After(1m) didn't fire after a minute")
+	}
+
+	ch = f.After(time.Hour)
+	f.Set(start.Add(2 * time.Hour))
+	select {
+	case <-ch:
+	default:
+		t.Fatal("After(1h) didn't fire when the clock was set past it")
+	}
+}
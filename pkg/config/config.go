@@ -1,49 +1,660 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+
+	"go-web-wa/pkg/imageutil"
+	"go-web-wa/pkg/logging"
+	"go-web-wa/pkg/netproxy"
+	"go-web-wa/pkg/publisher"
+	"go-web-wa/pkg/storage"
+	"go-web-wa/pkg/whatsapp"
 )
 
 // Config holds all configuration for the application
 type Config struct {
 	// WhatsApp Configuration
-	TargetPhoneNumber string
-	SessionFilePath   string
+	TargetPhoneNumber       string
+	TargetPhoneNumbers      []string
+	SessionFilePath         string
+	ProfileInfoTimeout      time.Duration
+	DownloadUserAgent       string
+	DownloadDialTimeout     time.Duration
+	DownloadTLSTimeout      time.Duration
+	DownloadHeaderTimeout   time.Duration
+	DownloadTimeout         time.Duration
+	ConnectStabilizeTimeout time.Duration
+	ConnectRetryAttempts    int
+	ConnectPollInterval     time.Duration
+	AppStateSyncTimeout     time.Duration
+	ReadyChecks             []string
+	ProfileCacheTTL         time.Duration
+	DefaultCountryCode      string
+	JIDServer               string
+	NonContactRetry         bool
+	IgnoreDefaultAvatars    bool
+	DefaultAvatarHashes     []string
+	SkipUnchanged           bool
+	ChangeCooldown          time.Duration
+	ConditionalDownloads    bool
+
+	// Session Encryption Configuration (optional)
+	SessionEncryptionKey         string
+	SessionEncryptionPreviousKey string
+
+	// Session Database Configuration
+	DatabasePath      string
+	SQLiteBusyTimeout time.Duration
+	SQLiteJournalMode string
+	SQLiteSynchronous string
+
+	// Pairing Configuration
+	QRStyle   string
+	QRLevel   string
+	QRPNGPath string
+
+	// PairWebAddr and PairWebToken configure the page served by pair --web
+	PairWebAddr  string
+	PairWebToken string
 
 	// Discord Configuration
-	DiscordWebhookURL string
+	DiscordWebhookURL   string
+	TargetWebhooks      map[string]string
+	PostImages          bool
+	PostAsGallery       bool
+	SendPlaceholder     bool
+	SendSummary         bool
+	WebhookEncoding     string
+	TitleTemplate       string
+	DescriptionTemplate string
+	InsecureSkipVerify  bool
+	WebhookRetries      int
+	WatermarkText       string
+	WatermarkPosition   string
+	MaxImageBytes       int
 
 	// Google Cloud Configuration (optional)
 	GoogleCloudProject string
 	GoogleCloudBucket  string
 
+	// Image Storage Configuration (optional)
+	StorageBackend string
+	StorageDir     string
+	StorageBaseURL string
+	StateFilePath  string
+	LastImagePath  string
+	RunArchiveDir  string
+	RunArchivePost bool
+	ReportDir      string
+	ReportKeep     int
+
+	// S3-compatible Storage Configuration (STORAGE_BACKEND=s3)
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	S3AccessKeyID      string
+	S3SecretAccessKey  string
+	SignedURLExpiry    time.Duration
+	SignedURLClockSkew time.Duration
+
+	// Placeholder Configuration (optional)
+	PlaceholderImagePath string
+
+	// Network Configuration (optional)
+	ProxyURL     string
+	ExtraCACerts string
+
+	// Event Publishing Configuration (optional)
+	Publisher        string
+	PublisherURL     string
+	PublisherSubject string
+
+	// Result Callback Configuration (optional)
+	ResultCallbackURL string
+
+	// HTTP API Configuration (optional)
+	APIListenAddr string
+	APIToken      string
+	DaemonURL     string
+
 	// Application Configuration
-	LogLevel string
+	LogLevel           string
+	LogFormat          string
+	MaskPhoneNumbers   bool
+	Timezone           string
+	Location           *time.Location
+	PollInterval       time.Duration
+	PollJitter         time.Duration
+	PollSpread         bool
+	QuietSummary       bool
+	QuietInterval      time.Duration
+	FetchRetryAttempts int
+	FetchRetryBackoff  time.Duration
+	FailureBackoff     time.Duration
+	FailureCooldown    time.Duration
+	FailureThreshold   int
+	FetchConcurrency   int
+	DownloadLimit      int
+	FetchOnOnline      bool
+	TrackStatus        bool
+	KeepaliveInterval  time.Duration
+	MaxRunDuration     time.Duration
+	Heartbeat          bool
+
+	// envErrs holds the variables Parse couldn't read
+	envErrs []error
 }
 
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
+	return LoadWithTargets(nil)
+}
+
+// LoadWithTargets loads configuration from environment variables, using targets
+// instead of TARGET_PHONE_NUMBER when any are given
+func LoadWithTargets(targets []string) (*Config, error) {
+	config := Parse(targets)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Parse reads configuration from environment variables without validating it
+func Parse(targets []string) *Config {
+	env := &envReader{}
 	config := &Config{
-		TargetPhoneNumber:  getEnv("TARGET_PHONE_NUMBER", ""),
-		SessionFilePath:    getEnv("SESSION_FILE_PATH", "./sessions/"),
-		DiscordWebhookURL:  getEnv("DISCORD_WEBHOOK_URL", ""),
+		// WhatsApp Configuration
+		TargetPhoneNumber:       getEnv("TARGET_PHONE_NUMBER", ""),
+		SessionFilePath:         getEnv("SESSION_FILE_PATH", "./sessions/"),
+		ProfileInfoTimeout:      time.Duration(env.getInt("PROFILE_INFO_TIMEOUT_SECONDS", 15)) * time.Second,
+		DownloadUserAgent:       getEnv("DOWNLOAD_USER_AGENT", ""),
+		DownloadDialTimeout:     time.Duration(env.getInt("DOWNLOAD_DIAL_TIMEOUT_SECONDS", 10)) * time.Second,
+		DownloadTLSTimeout:      time.Duration(env.getInt("DOWNLOAD_TLS_TIMEOUT_SECONDS", 10)) * time.Second,
+		DownloadHeaderTimeout:   time.Duration(env.getInt("DOWNLOAD_RESPONSE_HEADER_TIMEOUT_SECONDS", 15)) * time.Second,
+		DownloadTimeout:         time.Duration(env.getInt("DOWNLOAD_TIMEOUT_SECONDS", 60)) * time.Second,
+		ConnectStabilizeTimeout: time.Duration(env.getInt("CONNECT_STABILIZE_TIMEOUT_SECONDS", 10)) * time.Second,
+		ConnectRetryAttempts:    env.getInt("CONNECT_RETRY_ATTEMPTS", 3),
+		ConnectPollInterval:     time.Duration(env.getInt("CONNECT_POLL_INTERVAL_MS", 500)) * time.Millisecond,
+		AppStateSyncTimeout:     time.Duration(env.getInt("APP_STATE_SYNC_TIMEOUT_SECONDS", 5)) * time.Second,
+		ReadyChecks:             splitList(strings.ToLower(getEnv("READY_CHECKS", "connected,app_state"))),
+		ProfileCacheTTL:         time.Duration(env.getInt("PROFILE_CACHE_TTL_SECONDS", 0)) * time.Second,
+		DefaultCountryCode:      getEnv("DEFAULT_COUNTRY_CODE", ""),
+		JIDServer:               strings.ToLower(getEnv("JID_SERVER", "s.whatsapp.net")),
+		NonContactRetry:         env.getBool("NON_CONTACT_RETRY", false),
+		IgnoreDefaultAvatars:    env.getBool("IGNORE_DEFAULT_AVATARS", false),
+		DefaultAvatarHashes:     splitList(getEnv("DEFAULT_AVATAR_HASHES", "")),
+		SkipUnchanged:           env.getBool("SKIP_UNCHANGED", true),
+		ChangeCooldown:          time.Duration(env.getInt("CHANGE_COOLDOWN_SECONDS", 0)) * time.Second,
+		ConditionalDownloads:    env.getBool("CONDITIONAL_DOWNLOADS", true),
+
+		// Session Encryption Configuration
+		SessionEncryptionKey:         env.getSecret("SESSION_ENCRYPTION_KEY"),
+		SessionEncryptionPreviousKey: env.getSecret("SESSION_ENCRYPTION_PREVIOUS_KEY"),
+
+		// Session Database Configuration
+		DatabasePath:      DatabasePath(),
+		SQLiteBusyTimeout: time.Duration(env.getInt("SQLITE_BUSY_TIMEOUT_MS", 5000)) * time.Millisecond,
+		SQLiteJournalMode: strings.ToUpper(getEnv("SQLITE_JOURNAL_MODE", "WAL")),
+		SQLiteSynchronous: strings.ToUpper(getEnv("SQLITE_SYNCHRONOUS", "NORMAL")),
+
+		// Discord Configuration
+		DiscordWebhookURL:   env.getSecret("DISCORD_WEBHOOK_URL"),
+		TargetWebhooks:      make(map[string]string),
+		PostImages:          env.getBool("POST_IMAGES", true),
+		PostAsGallery:       env.getBool("POST_AS_GALLERY", false),
+		SendPlaceholder:     env.getBool("SEND_PLACEHOLDER", false),
+		SendSummary:         env.getBool("SEND_SUMMARY", false),
+		WebhookEncoding:     getEnv("WEBHOOK_ENCODING", "json"),
+		TitleTemplate:       getEnv("EMBED_TITLE_TEMPLATE", ""),
+		DescriptionTemplate: getEnv("EMBED_DESCRIPTION_TEMPLATE", ""),
+		InsecureSkipVerify:  env.getBool("INSECURE_SKIP_VERIFY", false),
+		WebhookRetries:      env.getInt("DISCORD_RETRY_ATTEMPTS", 3),
+		WatermarkText:       getEnv("WATERMARK_TEXT", ""),
+		WatermarkPosition:   getEnv("WATERMARK_POSITION", "bottom-right"),
+		MaxImageBytes:       env.getInt("MAX_IMAGE_BYTES", 8<<20),
+
+		// Google Cloud Configuration
 		GoogleCloudProject: getEnv("GOOGLE_CLOUD_PROJECT", ""),
 		GoogleCloudBucket:  getEnv("GOOGLE_CLOUD_BUCKET", ""),
-		LogLevel:           getEnv("LOG_LEVEL", "info"),
+
+		// Image Storage Configuration
+		StorageBackend: strings.ToLower(getEnv("STORAGE_BACKEND", "local")),
+		StorageDir:     getEnv("STORAGE_DIR", ""),
+		StorageBaseURL: getEnv("STORAGE_BASE_URL", ""),
+		StateFilePath:  getEnv("STATE_FILE_PATH", ""),
+		LastImagePath:  getEnv("LAST_IMAGE_PATH", ""),
+		RunArchiveDir:  getEnv("RUN_ARCHIVE_DIR", ""),
+		RunArchivePost: env.getBool("RUN_ARCHIVE_POST", false),
+		ReportDir:      getEnv("REPORT_DIR", ""),
+		ReportKeep:     env.getInt("REPORT_KEEP", 100),
+
+		// S3-compatible Storage Configuration
+		S3Endpoint:         getEnv("S3_ENDPOINT", ""),
+		S3Region:           getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:           getEnv("S3_BUCKET", ""),
+		S3AccessKeyID:      env.getSecret("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey:  env.getSecret("S3_SECRET_ACCESS_KEY"),
+		SignedURLExpiry:    time.Duration(env.getInt("SIGNED_URL_EXPIRY_SECONDS", 86400)) * time.Second,
+		SignedURLClockSkew: time.Duration(env.getInt("SIGNED_URL_CLOCK_SKEW_SECONDS", 300)) * time.Second,
+
+		// Placeholder Configuration
+		PlaceholderImagePath: getEnv("PLACEHOLDER_IMAGE_PATH", ""),
+
+		// Network Configuration
+		ProxyURL:     env.getSecret("PROXY_URL"),
+		ExtraCACerts: getEnv("EXTRA_CA_CERTS", ""),
+
+		// Event Publishing Configuration
+		Publisher:        strings.ToLower(getEnv("PUBLISHER", "")),
+		PublisherURL:     env.getSecret("PUBLISHER_URL"),
+		PublisherSubject: getEnv("PUBLISHER_SUBJECT", publisher.DefaultSubject),
+
+		// Result Callback Configuration
+		ResultCallbackURL: env.getSecret("RESULT_CALLBACK_URL"),
+
+		// HTTP API Configuration
+		APIListenAddr: getEnv("API_LISTEN_ADDR", ""),
+		APIToken:      env.getSecret("API_TOKEN"),
+		DaemonURL:     getEnv("DAEMON_URL", ""),
+
+		// Application Configuration
+		LogLevel:           LogLevel(),
+		LogFormat:          LogFormat(),
+		MaskPhoneNumbers:   env.getBool("MASK_PHONE_NUMBERS", false),
+		Timezone:           getEnv("TIMEZONE", ""),
+		PollInterval:       time.Duration(env.getInt("POLL_INTERVAL_SECONDS", 3600)) * time.Second,
+		PollJitter:         time.Duration(env.getInt("POLL_JITTER_SECONDS", 0)) * time.Second,
+		PollSpread:         env.getBool("POLL_SPREAD", false),
+		QuietSummary:       env.getBool("QUIET_SUMMARY", false),
+		QuietInterval:      time.Duration(env.getInt("QUIET_SUMMARY_INTERVAL_SECONDS", 3600)) * time.Second,
+		FetchRetryAttempts: env.getInt("FETCH_RETRY_ATTEMPTS", 3),
+		FetchRetryBackoff:  time.Duration(env.getInt("FETCH_RETRY_BACKOFF_SECONDS", 5)) * time.Second,
+		FailureBackoff:     time.Duration(env.getInt("FAILURE_BACKOFF_SECONDS", 0)) * time.Second,
+		FailureCooldown:    time.Duration(env.getInt("FAILURE_COOLDOWN_SECONDS", 86400)) * time.Second,
+		FailureThreshold:   env.getInt("FAILURE_ALERT_THRESHOLD", 5),
+		FetchConcurrency:   env.getInt("FETCH_CONCURRENCY", 4),
+		DownloadLimit:      env.getInt("DOWNLOAD_CONCURRENCY", 8),
+		FetchOnOnline:      env.getBool("FETCH_ON_ONLINE", false),
+		TrackStatus:        env.getBool("TRACK_STATUS", false),
+		KeepaliveInterval:  time.Duration(env.getInt("KEEPALIVE_INTERVAL_SECONDS", 0)) * time.Second,
+		MaxRunDuration:     time.Duration(env.getInt("MAX_RUN_SECONDS", 300)) * time.Second,
+		Heartbeat:          env.getBool("HEARTBEAT", false),
 	}
 
-	// Validate required fields
-	if config.TargetPhoneNumber == "" {
-		return nil, fmt.Errorf("TARGET_PHONE_NUMBER is required")
+	config.QRStyle, config.QRLevel, config.QRPNGPath = QRSettings()
+	config.PairWebAddr = PairWebAddr()
+	config.PairWebToken = env.getSecret("PAIR_WEB_TOKEN")
+
+	for _, pair := range splitList(env.getSecret("TARGET_WEBHOOK_MAP")) {
+		number, webhookURL, ok := strings.Cut(pair, "=")
+		number, webhookURL = strings.TrimSpace(number), strings.TrimSpace(webhookURL)
+		if !ok || number == "" || webhookURL == "" {
+			env.errs = append(env.errs, fmt.Errorf("TARGET_WEBHOOK_MAP entries must be number=url, got %q", pair))
+			continue
+		}
+		config.TargetWebhooks[routeKey(number)] = webhookURL
 	}
 
-	if config.DiscordWebhookURL == "" {
-		return nil, fmt.Errorf("DISCORD_WEBHOOK_URL is required")
+	config.TargetPhoneNumbers = splitList(config.TargetPhoneNumber)
+	if len(targets) > 0 {
+		config.TargetPhoneNumbers = targets
 	}
 
-	return config, nil
+	// Keep the state file and last image cache next to the session by default
+	if config.StateFilePath == "" {
+		config.StateFilePath = StateFilePath()
+	}
+	if config.LastImagePath == "" && env.getBool("CACHE_LAST_IMAGE", true) {
+		config.LastImagePath = filepath.Join(config.SessionFilePath, "last_image.jpg")
+	}
+
+	config.envErrs = env.errs
+	return config
+}
+
+// Validate checks the configuration and returns every problem found, joined
+// with errors.Join. It also resolves Location from Timezone.
+func (c *Config) Validate() error {
+	errs := append([]error{}, c.envErrs...)
+
+	if len(c.TargetPhoneNumbers) == 0 {
+		errs = append(errs, errors.New("TARGET_PHONE_NUMBER is required"))
+	}
+	for _, target := range c.TargetPhoneNumbers {
+		if err := ValidateTarget(target); err != nil {
+			errs = append(errs, fmt.Errorf("TARGET_PHONE_NUMBER: %w", err))
+		}
+	}
+
+	if code := strings.TrimPrefix(c.DefaultCountryCode, "+"); code != "" && strings.Trim(code, "0123456789") != "" {
+		errs = append(errs, fmt.Errorf("DEFAULT_COUNTRY_CODE must be digits, got %q", c.DefaultCountryCode))
+	}
+	if c.JIDServer == "" || strings.ContainsAny(c.JIDServer, "@: ") {
+		errs = append(errs, fmt.Errorf("JID_SERVER must be a bare server name such as s.whatsapp.net or lid, got %q", c.JIDServer))
+	}
+	// No default avatars are built in, so ignoring them needs the hashes
+	if c.IgnoreDefaultAvatars && len(c.DefaultAvatarHashes) == 0 {
+		errs = append(errs, errors.New("IGNORE_DEFAULT_AVATARS needs DEFAULT_AVATAR_HASHES; no default avatar hashes are built in"))
+	}
+
+	// An empty TIMEZONE keeps the server's local time
+	if location, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("TIMEZONE %q is not a valid IANA time zone: %w", c.Timezone, err))
+	} else if c.Timezone == "" {
+		c.Location = time.Local
+	} else {
+		c.Location = location
+	}
+
+	switch c.SQLiteJournalMode {
+	case "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF":
+	default:
+		errs = append(errs, fmt.Errorf("SQLITE_JOURNAL_MODE must be DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF, got %q", c.SQLiteJournalMode))
+	}
+	switch c.SQLiteSynchronous {
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		errs = append(errs, fmt.Errorf("SQLITE_SYNCHRONOUS must be OFF, NORMAL, FULL or EXTRA, got %q", c.SQLiteSynchronous))
+	}
+	if c.SQLiteBusyTimeout < 0 {
+		errs = append(errs, errors.New("SQLITE_BUSY_TIMEOUT_MS must not be negative"))
+	}
+	if c.DatabasePath != "" {
+		if err := checkWritableDir(filepath.Dir(c.DatabasePath)); err != nil {
+			errs = append(errs, fmt.Errorf("DATABASE_PATH: %w", err))
+		}
+	}
+
+	switch c.QRStyle {
+	case "half-block", "full-block", "png":
+	default:
+		errs = append(errs, fmt.Errorf("QR_STYLE must be half-block, full-block or png, got %q", c.QRStyle))
+	}
+	switch c.QRLevel {
+	case "L", "M", "Q", "H":
+	default:
+		errs = append(errs, fmt.Errorf("QR_LEVEL must be L, M, Q or H, got %q", c.QRLevel))
+	}
+
+	if _, err := netproxy.Parse(c.ProxyURL); err != nil {
+		errs = append(errs, fmt.Errorf("PROXY_URL: %w", err))
+	}
+	if _, err := netproxy.LoadCertPool(c.ExtraCACerts); err != nil {
+		errs = append(errs, fmt.Errorf("EXTRA_CA_CERTS: %w", err))
+	}
+
+	switch c.Publisher {
+	case "":
+	case "nats", "redis":
+		if c.PublisherURL == "" {
+			errs = append(errs, fmt.Errorf("PUBLISHER_URL is required when PUBLISHER is %s", c.Publisher))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("PUBLISHER must be nats or redis, got %q", c.Publisher))
+	}
+
+	switch c.StorageBackend {
+	case "local":
+	case "s3":
+		if c.S3Bucket == "" || c.S3AccessKeyID == "" || c.S3SecretAccessKey == "" {
+			errs = append(errs, errors.New("S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required when STORAGE_BACKEND is s3"))
+		}
+		if c.SignedURLExpiry <= 0 || c.SignedURLClockSkew < 0 {
+			errs = append(errs, errors.New("SIGNED_URL_EXPIRY_SECONDS must be positive and SIGNED_URL_CLOCK_SKEW_SECONDS not negative"))
+		}
+		if c.SignedURLExpiry+c.SignedURLClockSkew > storage.MaxSignedURLExpiry {
+			errs = append(errs, fmt.Errorf("SIGNED_URL_EXPIRY_SECONDS plus SIGNED_URL_CLOCK_SKEW_SECONDS must be at most %d", int(storage.MaxSignedURLExpiry.Seconds())))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_BACKEND must be local or s3, got %q", c.StorageBackend))
+	}
+
+	if c.APIListenAddr != "" && c.APIToken == "" {
+		errs = append(errs, errors.New("API_TOKEN is required when API_LISTEN_ADDR is set"))
+	}
+
+	if c.ResultCallbackURL != "" {
+		if u, err := url.Parse(c.ResultCallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("RESULT_CALLBACK_URL must be an http(s) URL"))
+		}
+	}
+	if c.DaemonURL != "" {
+		if u, err := url.Parse(c.DaemonURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("DAEMON_URL must be an http(s) URL, got %q", c.DaemonURL))
+		}
+		if c.APIToken == "" {
+			errs = append(errs, errors.New("API_TOKEN is required when DAEMON_URL is set"))
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be text or json, got %q", c.LogFormat))
+	}
+
+	if c.PollInterval <= 0 {
+		errs = append(errs, errors.New("POLL_INTERVAL_SECONDS must be positive"))
+	}
+
+	if c.PollJitter < 0 {
+		errs = append(errs, errors.New("POLL_JITTER_SECONDS must not be negative"))
+	}
+
+	if c.QuietSummary && c.QuietInterval <= 0 {
+		errs = append(errs, errors.New("QUIET_SUMMARY_INTERVAL_SECONDS must be positive"))
+	}
+
+	if c.FailureBackoff < 0 {
+		errs = append(errs, errors.New("FAILURE_BACKOFF_SECONDS must not be negative"))
+	}
+	if c.FailureBackoff > 0 {
+		if c.FailureCooldown < c.FailureBackoff {
+			errs = append(errs, errors.New("FAILURE_COOLDOWN_SECONDS must be at least FAILURE_BACKOFF_SECONDS"))
+		}
+		if c.FailureThreshold < 1 {
+			errs = append(errs, errors.New("FAILURE_ALERT_THRESHOLD must be at least 1"))
+		}
+	}
+
+	if c.ChangeCooldown < 0 {
+		errs = append(errs, errors.New("CHANGE_COOLDOWN_SECONDS must not be negative"))
+	}
+
+	if c.MaxRunDuration < 0 {
+		errs = append(errs, errors.New("MAX_RUN_SECONDS must not be negative"))
+	}
+
+	for _, check := range c.ReadyChecks {
+		switch check {
+		case "connected", "app_state", "offline_sync":
+		default:
+			errs = append(errs, fmt.Errorf("READY_CHECKS entries must be connected, app_state or offline_sync, got %q", check))
+		}
+	}
+
+	if c.ConnectRetryAttempts < 1 {
+		errs = append(errs, errors.New("CONNECT_RETRY_ATTEMPTS must be at least 1"))
+	}
+	if c.ConnectPollInterval <= 0 {
+		errs = append(errs, errors.New("CONNECT_POLL_INTERVAL_MS must be positive"))
+	}
+
+	if c.FetchRetryAttempts < 1 {
+		errs = append(errs, errors.New("FETCH_RETRY_ATTEMPTS must be at least 1"))
+	}
+
+	if c.FetchConcurrency < 1 {
+		errs = append(errs, errors.New("FETCH_CONCURRENCY must be at least 1"))
+	}
+	if c.FetchConcurrency > whatsapp.MaxFetchConcurrency {
+		errs = append(errs, fmt.Errorf("FETCH_CONCURRENCY must be at most %d to avoid WhatsApp rate limits", whatsapp.MaxFetchConcurrency))
+	}
+	if c.DownloadLimit < 1 {
+		errs = append(errs, errors.New("DOWNLOAD_CONCURRENCY must be at least 1"))
+	}
+
+	if c.DiscordWebhookURL == "" {
+		errs = append(errs, errors.New("DISCORD_WEBHOOK_URL is required"))
+	}
+
+	if c.MaxImageBytes < 0 {
+		errs = append(errs, errors.New("MAX_IMAGE_BYTES must not be negative"))
+	}
+
+	if c.ReportKeep < 0 {
+		errs = append(errs, errors.New("REPORT_KEEP must not be negative"))
+	}
+
+	if _, err := imageutil.ParseCorner(c.WatermarkPosition); err != nil {
+		errs = append(errs, fmt.Errorf("WATERMARK_POSITION: %w", err))
+	}
+
+	for number, webhookURL := range c.TargetWebhooks {
+		if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("TARGET_WEBHOOK_MAP: invalid webhook URL for %s", number))
+		}
+	}
+
+	if c.WebhookEncoding != "json" && c.WebhookEncoding != "form" {
+		errs = append(errs, fmt.Errorf("WEBHOOK_ENCODING must be json or form, got %q", c.WebhookEncoding))
+	}
+	if c.WebhookRetries < 1 {
+		errs = append(errs, errors.New("DISCORD_RETRY_ATTEMPTS must be at least 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// WebhookURLFor returns the webhook a number's updates are posted to: its
+// TARGET_WEBHOOK_MAP entry, or DISCORD_WEBHOOK_URL
+func (c *Config) WebhookURLFor(number string) string {
+	if webhookURL, ok := c.TargetWebhooks[routeKey(number)]; ok {
+		return webhookURL
+	}
+	return c.DiscordWebhookURL
+}
+
+// routeKey normalizes a number so "+62 812-345" and "62812345" map to the same webhook
+func routeKey(number string) string {
+	if strings.Contains(number, "@") {
+		return strings.TrimSpace(number)
+	}
+	return strings.NewReplacer("+", "", "-", "", " ", "").Replace(number)
+}
+
+// ValidateTarget checks that a target is a JID, a channel link or a phone
+// number of up to 15 digits, optionally with a leading + and separating spaces or dashes
+func ValidateTarget(target string) error {
+	if invite, ok := strings.CutPrefix(target, "https://whatsapp.com/channel/"); ok {
+		if invite == "" || strings.Contains(invite, "/") {
+			return fmt.Errorf("invalid channel link %q", target)
+		}
+		return nil
+	}
+	if strings.Contains(target, "@") {
+		if user, server, _ := strings.Cut(target, "@"); user == "" || server == "" {
+			return fmt.Errorf("invalid JID %q", target)
+		}
+		return nil
+	}
+
+	digits := strings.NewReplacer("-", "", " ", "").Replace(strings.TrimPrefix(target, "+"))
+	if digits == "" || strings.Trim(digits, "0123456789") != "" || strings.Contains(strings.TrimPrefix(target, "+"), "+") {
+		return fmt.Errorf("invalid phone number %q: use digits, optionally with a leading +", target)
+	}
+	if len(digits) > 15 {
+		return fmt.Errorf("invalid phone number %q: longer than 15 digits", target)
+	}
+	return nil
+}
+
+// Secret returns the environment variable key, or the contents of the file
+// named by key_FILE with surrounding whitespace trimmed. The file takes
+// precedence, so a mounted secret wins over a leftover variable.
+func Secret(key string) (string, error) {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return os.Getenv(key), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", key, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// LogFormat returns LOG_FORMAT, defaulting to text. Unlike Load it needs no other settings.
+func LogFormat() string {
+	return strings.ToLower(getEnv("LOG_FORMAT", "text"))
+}
+
+// MaskPhoneNumbers reports whether MASK_PHONE_NUMBERS is set. Unlike Load it
+// needs no other settings; malformed values are reported by Validate.
+func MaskPhoneNumbers() bool {
+	mask, _ := strconv.ParseBool(strings.TrimSpace(getEnv("MASK_PHONE_NUMBERS", "false")))
+	return mask
+}
+
+// LogLevel returns LOG_LEVEL, defaulting to info. Unlike Load it needs no other settings.
+func LogLevel() string {
+	return strings.ToLower(getEnv("LOG_LEVEL", "info"))
+}
+
+// QRSettings returns QR_STYLE, QR_LEVEL and QR_PNG_PATH, defaulting to
+// half-block codes at level L. Unlike Load it needs no other settings.
+func QRSettings() (style, level, pngPath string) {
+	return strings.ToLower(getEnv("QR_STYLE", "half-block")),
+		strings.ToUpper(getEnv("QR_LEVEL", "L")),
+		getEnv("QR_PNG_PATH", "pairing-qr.png")
+}
+
+// PairWebAddr returns PAIR_WEB_ADDR, where pair --web serves the pairing
+// page, defaulting to localhost only. Unlike Load it needs no other settings.
+func PairWebAddr() string {
+	return getEnv("PAIR_WEB_ADDR", "127.0.0.1:8090")
+}
+
+// StateFilePath returns the state file location from STATE_FILE_PATH, defaulting
+// to state.json in the session directory. Unlike Load it needs no other settings.
+func StateFilePath() string {
+	if path := getEnv("STATE_FILE_PATH", ""); path != "" {
+		return path
+	}
+	return filepath.Join(getEnv("SESSION_FILE_PATH", "./sessions/"), "state.json")
+}
+
+// DatabasePath returns DATABASE_PATH, or an empty string to keep whatsapp.db
+// in SESSION_FILE_PATH. Unlike Load it needs no other settings.
+func DatabasePath() string {
+	return getEnv("DATABASE_PATH", "")
+}
+
+// checkWritableDir fails unless dir exists and a file can be created in it
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("directory %s is not accessible: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
 }
 
 // getEnv gets an environment variable with a default value
@@ -54,22 +665,56 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// getEnvAsInt gets an environment variable as integer with a default value
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return defaultValue
+	return items
 }
 
-// getEnvAsBool gets an environment variable as boolean with a default value
-func getEnvAsBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
-		}
+// envReader reads typed environment variables, collecting values that don't
+// parse instead of silently using the default
+type envReader struct {
+	errs []error
+}
+
+// getInt gets an environment variable as integer with a default value
+func (e *envReader) getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
 	}
-	return defaultValue
+	intValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a whole number, got %q", key, value))
+		return defaultValue
+	}
+	return intValue
+}
+
+// getSecret gets a secret with Secret, recording a failed read
+func (e *envReader) getSecret(key string) string {
+	value, err := Secret(key)
+	if err != nil {
+		e.errs = append(e.errs, err)
+	}
+	return value
+}
+
+// getBool gets an environment variable as boolean with a default value
+func (e *envReader) getBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s must be true or false, got %q", key, value))
+		return defaultValue
+	}
+	return boolValue
 }
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces secret values in Settings
+const redacted = "<redacted>"
+
+// Setting is one effective configuration value, named after the environment
+// variable it comes from
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// Settings lists the effective configuration, defaults included, with tokens,
+// keys and URL passwords redacted so the output is safe to share
+func (c *Config) Settings() []Setting {
+	return []Setting{
+		// WhatsApp Configuration
+		{"TARGET_PHONE_NUMBER", strings.Join(c.TargetPhoneNumbers, ",")},
+		{"SESSION_FILE_PATH", c.SessionFilePath},
+		{"PROFILE_INFO_TIMEOUT_SECONDS", seconds(c.ProfileInfoTimeout)},
+		{"DOWNLOAD_USER_AGENT", c.DownloadUserAgent},
+		{"DOWNLOAD_DIAL_TIMEOUT_SECONDS", seconds(c.DownloadDialTimeout)},
+		{"DOWNLOAD_TLS_TIMEOUT_SECONDS", seconds(c.DownloadTLSTimeout)},
+		{"DOWNLOAD_RESPONSE_HEADER_TIMEOUT_SECONDS", seconds(c.DownloadHeaderTimeout)},
+		{"DOWNLOAD_TIMEOUT_SECONDS", seconds(c.DownloadTimeout)},
+		{"CONNECT_STABILIZE_TIMEOUT_SECONDS", seconds(c.ConnectStabilizeTimeout)},
+		{"CONNECT_RETRY_ATTEMPTS", strconv.Itoa(c.ConnectRetryAttempts)},
+		{"CONNECT_POLL_INTERVAL_MS", strconv.FormatInt(c.ConnectPollInterval.Milliseconds(), 10)},
+		{"APP_STATE_SYNC_TIMEOUT_SECONDS", seconds(c.AppStateSyncTimeout)},
+		{"READY_CHECKS", strings.Join(c.ReadyChecks, ",")},
+		{"PROFILE_CACHE_TTL_SECONDS", seconds(c.ProfileCacheTTL)},
+		{"DEFAULT_COUNTRY_CODE", c.DefaultCountryCode},
+		{"JID_SERVER", c.JIDServer},
+		{"NON_CONTACT_RETRY", strconv.FormatBool(c.NonContactRetry)},
+		{"IGNORE_DEFAULT_AVATARS", strconv.FormatBool(c.IgnoreDefaultAvatars)},
+		{"DEFAULT_AVATAR_HASHES", strings.Join(c.DefaultAvatarHashes, ",")},
+		{"SKIP_UNCHANGED", strconv.FormatBool(c.SkipUnchanged)},
+		{"CHANGE_COOLDOWN_SECONDS", seconds(c.ChangeCooldown)},
+		{"CONDITIONAL_DOWNLOADS", strconv.FormatBool(c.ConditionalDownloads)},
+
+		// Session Encryption Configuration
+		{"SESSION_ENCRYPTION_KEY", secret(c.SessionEncryptionKey)},
+		{"SESSION_ENCRYPTION_PREVIOUS_KEY", secret(c.SessionEncryptionPreviousKey)},
+
+		// Session Database Configuration
+		{"DATABASE_PATH", c.DatabasePath},
+		{"SQLITE_BUSY_TIMEOUT_MS", strconv.FormatInt(c.SQLiteBusyTimeout.Milliseconds(), 10)},
+		{"SQLITE_JOURNAL_MODE", c.SQLiteJournalMode},
+		{"SQLITE_SYNCHRONOUS", c.SQLiteSynchronous},
+
+		// Pairing Configuration
+		{"QR_STYLE", c.QRStyle},
+		{"QR_LEVEL", c.QRLevel},
+		{"QR_PNG_PATH", c.QRPNGPath},
+		{"PAIR_WEB_ADDR", c.PairWebAddr},
+		{"PAIR_WEB_TOKEN", secret(c.PairWebToken)},
+
+		// Discord Configuration; the webhook URL embeds its token
+		{"DISCORD_WEBHOOK_URL", secret(c.DiscordWebhookURL)},
+		{"TARGET_WEBHOOK_MAP", redactWebhookMap(c.TargetWebhooks)},
+		{"POST_IMAGES", strconv.FormatBool(c.PostImages)},
+		{"POST_AS_GALLERY", strconv.FormatBool(c.PostAsGallery)},
+		{"SEND_PLACEHOLDER", strconv.FormatBool(c.SendPlaceholder)},
+		{"SEND_SUMMARY", strconv.FormatBool(c.SendSummary)},
+		{"WEBHOOK_ENCODING", c.WebhookEncoding},
+		{"EMBED_TITLE_TEMPLATE", c.TitleTemplate},
+		{"EMBED_DESCRIPTION_TEMPLATE", c.DescriptionTemplate},
+		{"INSECURE_SKIP_VERIFY", strconv.FormatBool(c.InsecureSkipVerify)},
+		{"DISCORD_RETRY_ATTEMPTS", strconv.Itoa(c.WebhookRetries)},
+		{"WATERMARK_TEXT", c.WatermarkText},
+		{"WATERMARK_POSITION", c.WatermarkPosition},
+		{"MAX_IMAGE_BYTES", strconv.Itoa(c.MaxImageBytes)},
+
+		// Google Cloud Configuration
+		{"GOOGLE_CLOUD_PROJECT", c.GoogleCloudProject},
+		{"GOOGLE_CLOUD_BUCKET", c.GoogleCloudBucket},
+
+		// Image Storage Configuration
+		{"STORAGE_BACKEND", c.StorageBackend},
+		{"STORAGE_DIR", c.StorageDir},
+		{"STORAGE_BASE_URL", c.StorageBaseURL},
+		{"STATE_FILE_PATH", c.StateFilePath},
+		{"LAST_IMAGE_PATH", c.LastImagePath},
+		{"RUN_ARCHIVE_DIR", c.RunArchiveDir},
+		{"RUN_ARCHIVE_POST", strconv.FormatBool(c.RunArchivePost)},
+		{"REPORT_DIR", c.ReportDir},
+		{"REPORT_KEEP", strconv.Itoa(c.ReportKeep)},
+
+		// S3-compatible Storage Configuration
+		{"S3_ENDPOINT", c.S3Endpoint},
+		{"S3_REGION", c.S3Region},
+		{"S3_BUCKET", c.S3Bucket},
+		{"S3_ACCESS_KEY_ID", c.S3AccessKeyID},
+		{"S3_SECRET_ACCESS_KEY", secret(c.S3SecretAccessKey)},
+		{"SIGNED_URL_EXPIRY_SECONDS", seconds(c.SignedURLExpiry)},
+		{"SIGNED_URL_CLOCK_SKEW_SECONDS", seconds(c.SignedURLClockSkew)},
+
+		// Placeholder Configuration
+		{"PLACEHOLDER_IMAGE_PATH", c.PlaceholderImagePath},
+
+		// Network Configuration
+		{"PROXY_URL", redactURL(c.ProxyURL)},
+		{"EXTRA_CA_CERTS", c.ExtraCACerts},
+
+		// Event Publishing Configuration
+		{"PUBLISHER", c.Publisher},
+		{"PUBLISHER_URL", redactURL(c.PublisherURL)},
+		{"PUBLISHER_SUBJECT", c.PublisherSubject},
+
+		// Result Callback Configuration; the URL may carry a token
+		{"RESULT_CALLBACK_URL", redactURL(c.ResultCallbackURL)},
+
+		// HTTP API Configuration
+		{"API_LISTEN_ADDR", c.APIListenAddr},
+		{"API_TOKEN", secret(c.APIToken)},
+		{"DAEMON_URL", redactURL(c.DaemonURL)},
+
+		// Application Configuration
+		{"LOG_LEVEL", c.LogLevel},
+		{"LOG_FORMAT", c.LogFormat},
+		{"MASK_PHONE_NUMBERS", strconv.FormatBool(c.MaskPhoneNumbers)},
+		{"TIMEZONE", c.Timezone},
+		{"POLL_INTERVAL_SECONDS", seconds(c.PollInterval)},
+		{"POLL_JITTER_SECONDS", seconds(c.PollJitter)},
+		{"POLL_SPREAD", strconv.FormatBool(c.PollSpread)},
+		{"QUIET_SUMMARY", strconv.FormatBool(c.QuietSummary)},
+		{"QUIET_SUMMARY_INTERVAL_SECONDS", seconds(c.QuietInterval)},
+		{"FETCH_RETRY_ATTEMPTS", strconv.Itoa(c.FetchRetryAttempts)},
+		{"FETCH_RETRY_BACKOFF_SECONDS", seconds(c.FetchRetryBackoff)},
+		{"FAILURE_BACKOFF_SECONDS", seconds(c.FailureBackoff)},
+		{"FAILURE_COOLDOWN_SECONDS", seconds(c.FailureCooldown)},
+		{"FAILURE_ALERT_THRESHOLD", strconv.Itoa(c.FailureThreshold)},
+		{"FETCH_CONCURRENCY", strconv.Itoa(c.FetchConcurrency)},
+		{"DOWNLOAD_CONCURRENCY", strconv.Itoa(c.DownloadLimit)},
+		{"FETCH_ON_ONLINE", strconv.FormatBool(c.FetchOnOnline)},
+		{"TRACK_STATUS", strconv.FormatBool(c.TrackStatus)},
+		{"KEEPALIVE_INTERVAL_SECONDS", seconds(c.KeepaliveInterval)},
+		{"MAX_RUN_SECONDS", seconds(c.MaxRunDuration)},
+		{"HEARTBEAT", strconv.FormatBool(c.Heartbeat)},
+	}
+}
+
+// seconds formats d the way the *_SECONDS variables are written
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// secret hides a set value and leaves an unset one empty
+func secret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactWebhookMap lists the routed numbers with their webhook URLs hidden
+func redactWebhookMap(webhooks map[string]string) string {
+	numbers := make([]string, 0, len(webhooks))
+	for number := range webhooks {
+		numbers = append(numbers, number+"="+redacted)
+	}
+	sort.Strings(numbers)
+	return strings.Join(numbers, ",")
+}
+
+// redactURL hides the password or token in a URL's user info
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return secret(value)
+	}
+	if u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		// A lone user name is a token, as in nats://token@host
+		u.User = url.User("xxxxx")
+		return u.String()
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,50 @@
+package correlation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// contextKey is the context key holding the correlation ID
+type contextKey struct{}
+
+// fieldsKey is the context key holding the log fields added with WithFields
+type fieldsKey struct{}
+
+// NewID generates a new correlation ID
+func NewID() string {
+	return uuid.NewString()
+}
+
+// WithID returns a copy of ctx carrying the correlation ID id
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the correlation ID carried by ctx, or "" if there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// WithFields returns a copy of ctx whose log lines carry the given key/value
+// pairs, such as the number or operation being worked on
+func WithFields(ctx context.Context, args ...any) context.Context {
+	fields := append(Fields(ctx), slog.Group("", args...).Value.Group()...)
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// Fields returns the log fields carried by ctx
+func Fields(ctx context.Context) []slog.Attr {
+	fields, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return append([]slog.Attr(nil), fields...)
+}
+
+// Logf logs like log.Printf at info level. The installed log handler adds the
+// correlation ID and fields from ctx.
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Encoder serializes message payloads for the webhook endpoint
+type Encoder interface {
+	// ContentType is the Content-Type of bodies produced by Encode
+	ContentType() string
+	// Encode serializes payload as a request body
+	Encode(payload MessagePayload) ([]byte, error)
+	// MultipartFields returns the form fields that carry payload in a file upload
+	MultipartFields(payload MessagePayload) (url.Values, error)
+}
+
+// JSONEncoder encodes payloads as JSON, as Discord expects
+type JSONEncoder struct{}
+
+// ContentType returns the JSON content type
+func (JSONEncoder) ContentType() string {
+	return "application/json"
+}
+
+// Encode marshals payload to JSON
+func (JSONEncoder) Encode(payload MessagePayload) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return body, nil
+}
+
+// MultipartFields puts the JSON payload in the payload_json field
+func (e JSONEncoder) MultipartFields(payload MessagePayload) (url.Values, error) {
+	body, err := e.Encode(payload)
+	if err != nil {
+		return nil, err
+	}
+	return url.Values{"payload_json": {string(body)}}, nil
+}
+
+// FormEncoder encodes payloads as flat form fields for endpoints that don't
+// accept JSON. Each embed adds title, description, color, timestamp, footer and
+// image_url values, and each embed field adds a field[Name] value; repeated
+// keys keep the embed order.
+type FormEncoder struct{}
+
+// ContentType returns the form content type
+func (FormEncoder) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+// Encode form-encodes payload
+func (e FormEncoder) Encode(payload MessagePayload) ([]byte, error) {
+	values, err := e.MultipartFields(payload)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+// MultipartFields flattens payload into form values
+func (FormEncoder) MultipartFields(payload MessagePayload) (url.Values, error) {
+	values := url.Values{}
+	addValue := func(key, value string) {
+		if value != "" {
+			values.Add(key, value)
+		}
+	}
+
+	addValue("content", payload.Content)
+	for _, embed := range payload.Embeds {
+		addValue("title", embed.Title)
+		addValue("description", embed.Description)
+		if embed.Color != 0 {
+			addValue("color", strconv.Itoa(embed.Color))
+		}
+		addValue("timestamp", embed.Timestamp)
+		if embed.Footer != nil {
+			addValue("footer", embed.Footer.Text)
+		}
+		if embed.Image != nil {
+			addValue("image_url", embed.Image.URL)
+		}
+		for _, field := range embed.Fields {
+			addValue("field["+field.Name+"]", field.Value)
+		}
+	}
+
+	return values, nil
+}
+
+// WithEncoder sets how payloads are serialized. The default JSONEncoder is what
+// Discord expects; FormEncoder targets endpoints that need form-encoded bodies.
+func WithEncoder(encoder Encoder) Option {
+	return func(c *WebhookClient) {
+		if encoder != nil {
+			c.encoder = encoder
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryAttempts is how many times a message is sent in total when
+// Discord keeps answering with 429 or a 5xx error
+const DefaultRetryAttempts = 3
+
+// retryBackoff is the first wait after a 5xx error; it doubles on each retry
+const retryBackoff = time.Second
+
+// maxRetryAfter caps how long a rate limit is waited out, so a bogus
+// Retry-After can't stall the fetcher
+const maxRetryAfter = time.Minute
+
+// WithRetryAttempts sets how many times a message is sent in total when
+// Discord answers with 429 or a 5xx error; 1 disables retries. Other 4xx
+// errors always fail at once.
+func WithRetryAttempts(attempts int) Option {
+	return func(c *WebhookClient) {
+		if attempts > 0 {
+			c.retryAttempts = attempts
+		}
+	}
+}
+
+// WithContext stops retries once ctx is done, and skips a retry whose wait
+// would run past ctx's deadline. Requests themselves aren't bound to ctx, so
+// messages sent while shutting down still go out.
+func WithContext(ctx context.Context) Option {
+	return func(c *WebhookClient) {
+		if ctx != nil {
+			c.retryCtx = ctx
+		}
+	}
+}
+
+// do sends the request built by newRequest and returns the response body.
+// Rate limits are retried after Retry-After and 5xx errors with exponential
+// backoff, up to the configured attempts and while the retry context allows;
+// the error then carries the last response body. newRequest is called again
+// for every attempt.
+func (c *WebhookClient) do(newRequest func() (*http.Request, error), attempts int) ([]byte, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode < 400 {
+			return body, nil
+		}
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable {
+			return nil, fmt.Errorf("discord webhook returned error: %d - %s", resp.StatusCode, string(body))
+		}
+		if attempt >= attempts {
+			return nil, fmt.Errorf("discord webhook returned error after %d attempts: %d - %s", attempt, resp.StatusCode, string(body))
+		}
+
+		wait := backoff
+		if resp.StatusCode == http.StatusTooManyRequests {
+			wait = retryAfter(resp.Header, body)
+		} else {
+			backoff *= 2
+		}
+		if deadline, ok := c.retryCtx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, fmt.Errorf("discord webhook returned error: %d - %s (retry in %v would pass the deadline): %w", resp.StatusCode, string(body), wait, context.DeadlineExceeded)
+		}
+		log.Printf("Discord webhook returned %d, retrying in %v (attempt %d/%d)", resp.StatusCode, wait, attempt, attempts)
+		select {
+		case <-c.retryCtx.Done():
+			return nil, fmt.Errorf("discord webhook returned error: %d - %s (retry cancelled): %w", resp.StatusCode, string(body), c.retryCtx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryAfter reads how long Discord asks to wait from the Retry-After header
+// or the retry_after field of the body, both in seconds
+func retryAfter(header http.Header, body []byte) time.Duration {
+	seconds, err := strconv.ParseFloat(header.Get("Retry-After"), 64)
+	if err != nil {
+		var limited struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		if json.Unmarshal(body, &limited) == nil {
+			seconds = limited.RetryAfter
+		}
+	}
+
+	wait := time.Duration(seconds * float64(time.Second))
+	if wait <= 0 {
+		return retryBackoff
+	}
+	return min(wait, maxRetryAfter)
+}
@@ -0,0 +1,78 @@
+package discord
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// ImageTemplateData is available to the image embed title and description templates
+type ImageTemplateData struct {
+	Number    string
+	Name      string
+	Timestamp time.Time
+	// ImageSize is the image size in bytes
+	ImageSize int
+}
+
+// ImageTemplates renders the title and description of profile image embeds
+type ImageTemplates struct {
+	title       *template.Template
+	description *template.Template
+}
+
+// Default templates matching the built-in embed text
+const (
+	DefaultImageTitleTemplate       = "WhatsApp Profile Image"
+	DefaultImageDescriptionTemplate = "Profile image for: {{.Number}}"
+)
+
+// NewImageTemplates parses the title and description templates, using the
+// defaults for empty ones. Templates are test-rendered so references to
+// unknown fields fail here rather than when an image is sent.
+func NewImageTemplates(title, description string) (*ImageTemplates, error) {
+	if title == "" {
+		title = DefaultImageTitleTemplate
+	}
+	if description == "" {
+		description = DefaultImageDescriptionTemplate
+	}
+
+	t := &ImageTemplates{}
+	var err error
+	if t.title, err = template.New("title").Parse(title); err != nil {
+		return nil, fmt.Errorf("invalid title template: %w", err)
+	}
+	if t.description, err = template.New("description").Parse(description); err != nil {
+		return nil, fmt.Errorf("invalid description template: %w", err)
+	}
+
+	sample := ImageTemplateData{Number: "+1234567890", Name: "Sample", Timestamp: time.Now(), ImageSize: 1024}
+	if _, _, err := t.render(sample); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// render executes both templates with data
+func (t *ImageTemplates) render(data ImageTemplateData) (string, string, error) {
+	var title, description bytes.Buffer
+	if err := t.title.Execute(&title, data); err != nil {
+		return "", "", fmt.Errorf("failed to render title template: %w", err)
+	}
+	if err := t.description.Execute(&description, data); err != nil {
+		return "", "", fmt.Errorf("failed to render description template: %w", err)
+	}
+	return title.String(), description.String(), nil
+}
+
+// WithImageTemplates sets the templates used for profile image embeds
+func WithImageTemplates(templates *ImageTemplates) Option {
+	return func(c *WebhookClient) {
+		if templates != nil {
+			c.templates = templates
+		}
+	}
+}
@@ -1,35 +1,169 @@
 package discord
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"go-web-wa/pkg/batch"
+	"go-web-wa/pkg/clock"
+	"go-web-wa/pkg/mask"
 )
 
 // WebhookClient handles Discord webhook operations
 type WebhookClient struct {
 	webhookURL string
 	httpClient *http.Client
+	clock      clock.Clock
+	encoder    Encoder
+	templates  *ImageTemplates
+	masker     *mask.Masker
+	version    string
+
+	insecureSkipVerify bool
+	retryAttempts      int
+	retryCtx           context.Context
+
+	// newBuffer returns the buffer for an in-memory multipart body; tests set
+	// it to simulate failing writes
+	newBuffer func() multipartBuffer
+}
+
+// Option configures optional WebhookClient behaviour
+type Option func(*WebhookClient)
+
+// WithClock sets the clock used for embed timestamps
+func WithClock(clk clock.Clock) Option {
+	return func(c *WebhookClient) {
+		c.clock = clk
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to call the webhook, e.g. one that goes through a proxy
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *WebhookClient) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithMasker masks phone numbers in the text and attachment filenames of every
+// message, so the posted text never carries a full number
+func WithMasker(m *mask.Masker) Option {
+	return func(c *WebhookClient) {
+		c.masker = m
+	}
+}
+
+// WithVersion adds the build version to error footers and heartbeats so
+// alerts can be matched with a release
+func WithVersion(version string) Option {
+	return func(c *WebhookClient) {
+		c.version = version
+	}
+}
+
+// WithInsecureSkipVerify disables TLS certificate verification so a webhook
+// receiver with a self-signed certificate can be used for testing. This is
+// unsafe: anyone on the network path can read and alter the requests. It is
+// ignored for Discord's own hosts.
+func WithInsecureSkipVerify(skip bool) Option {
+	return func(c *WebhookClient) {
+		c.insecureSkipVerify = skip
+	}
 }
 
 // NewWebhookClient creates a new Discord webhook client
-func NewWebhookClient(webhookURL string) *WebhookClient {
-	return &WebhookClient{
+func NewWebhookClient(webhookURL string, opts ...Option) *WebhookClient {
+	// The built-in templates always parse
+	defaultTemplates, _ := NewImageTemplates("", "")
+
+	client := &WebhookClient{
+		templates:  defaultTemplates,
 		webhookURL: webhookURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		clock:   clock.Real{},
+		encoder: JSONEncoder{},
+
+		retryAttempts: DefaultRetryAttempts,
+		retryCtx:      context.Background(),
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.insecureSkipVerify {
+		client.disableTLSVerification()
 	}
+
+	return client
+}
+
+// disableTLSVerification swaps in a copy of the HTTP client whose transport
+// skips certificate verification, leaving the caller's client untouched
+func (c *WebhookClient) disableTLSVerification() {
+	parsed, err := url.Parse(c.webhookURL)
+	if err != nil {
+		return
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, discordHost := range []string{"discord.com", "discordapp.com"} {
+		if host == discordHost || strings.HasSuffix(host, "."+discordHost) {
+			log.Printf("Ignoring INSECURE_SKIP_VERIFY for Discord host %s", host)
+			return
+		}
+	}
+
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		log.Printf("Cannot disable TLS verification for %s: unsupported HTTP transport %T", host, t)
+		return
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true
+
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+	slog.Warn("TLS certificate verification is disabled for webhook host", "host", host)
 }
 
 // MessagePayload represents a Discord webhook message payload
 type MessagePayload struct {
-	Content string  `json:"content,omitempty"`
-	Embeds  []Embed `json:"embeds,omitempty"`
+	Content     string          `json:"content,omitempty"`
+	Embeds      []Embed         `json:"embeds,omitempty"`
+	Attachments []AttachmentRef `json:"attachments,omitempty"`
+}
+
+// AttachmentRef lists an attachment to keep when editing a message; ID is the
+// index of a newly uploaded file
+type AttachmentRef struct {
+	ID int `json:"id"`
 }
 
 // Embed represents a Discord embed
@@ -40,6 +174,46 @@ type Embed struct {
 	Timestamp   string  `json:"timestamp,omitempty"`
 	Footer      *Footer `json:"footer,omitempty"`
 	Image       *Image  `json:"image,omitempty"`
+	Fields      []Field `json:"fields,omitempty"`
+}
+
+// Field represents a Discord embed field
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Discord rejects embeds whose fields exceed these limits
+const (
+	maxEmbedFields       = 25
+	maxFieldNameLength   = 256
+	maxFieldValueLength  = 1024
+	maxTitleLength       = 256
+	maxDescriptionLength = 4096
+)
+
+// AddField appends a field to the embed. Fields with an empty name or value are
+// skipped and long text is truncated so the embed stays within Discord's limits.
+func (e *Embed) AddField(name, value string, inline bool) {
+	if name == "" || value == "" || len(e.Fields) >= maxEmbedFields {
+		return
+	}
+
+	e.Fields = append(e.Fields, Field{
+		Name:   truncate(name, maxFieldNameLength),
+		Value:  truncate(value, maxFieldValueLength),
+		Inline: inline,
+	})
+}
+
+// truncate shortens s to at most limit characters, marking the cut with an ellipsis
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
 }
 
 // Footer represents a Discord embed footer
@@ -63,15 +237,29 @@ func (c *WebhookClient) SendMessage(message string) error {
 
 // SendErrorMessage sends an error message with embed styling
 func (c *WebhookClient) SendErrorMessage(title, description string) error {
+	return c.SendErrorMessageWithID(title, description, "")
+}
+
+// SendErrorMessageWithID sends an error message whose footer carries a
+// correlation ID, so the failure can be matched with its log lines
+func (c *WebhookClient) SendErrorMessageWithID(title, description, correlationID string) error {
+	footer := "WhatsApp Profile Fetcher"
+	if c.version != "" {
+		footer += " " + c.version
+	}
+	if correlationID != "" {
+		footer += " • " + correlationID
+	}
+
 	payload := MessagePayload{
 		Embeds: []Embed{
 			{
 				Title:       title,
 				Description: description,
 				Color:       0xFF0000, // Red color for errors
-				Timestamp:   time.Now().Format(time.RFC3339),
+				Timestamp:   c.timestamp(),
 				Footer: &Footer{
-					Text: "WhatsApp Profile Fetcher",
+					Text: footer,
 				},
 			},
 		},
@@ -80,6 +268,59 @@ func (c *WebhookClient) SendErrorMessage(title, description string) error {
 	return c.sendPayload(payload)
 }
 
+// SendStatusChange posts the old and new "about" text of a contact
+func (c *WebhookClient) SendStatusChange(phoneNumber, name, oldStatus, newStatus string) error {
+	title := "Status Changed: " + phoneNumber
+	if name != "" {
+		title = fmt.Sprintf("Status Changed: %s (%s)", name, phoneNumber)
+	}
+
+	embed := Embed{
+		Title:     title,
+		Color:     0x5865F2, // Blurple for informational updates
+		Timestamp: c.timestamp(),
+		Footer: &Footer{
+			Text: "WhatsApp Profile Fetcher",
+		},
+	}
+	embed.AddField("Previous", statusText(oldStatus), false)
+	embed.AddField("New", statusText(newStatus), false)
+
+	return c.sendPayload(MessagePayload{Embeds: []Embed{embed}})
+}
+
+// SendPictureRemoved posts that a contact removed their profile picture;
+// pictureSince is when the removed picture was first seen, zero if unknown
+func (c *WebhookClient) SendPictureRemoved(phoneNumber, name string, pictureSince time.Time) error {
+	title := "Profile Picture Removed: " + phoneNumber
+	if name != "" {
+		title = fmt.Sprintf("Profile Picture Removed: %s (%s)", name, phoneNumber)
+	}
+
+	embed := Embed{
+		Title:       title,
+		Description: "The contact no longer has a profile picture",
+		Color:       0xFFA500, // Orange for removals
+		Timestamp:   c.timestamp(),
+		Footer: &Footer{
+			Text: "WhatsApp Profile Fetcher",
+		},
+	}
+	if !pictureSince.IsZero() {
+		embed.AddField("Picture Seen Since", fmt.Sprintf("<t:%d:R>", pictureSince.Unix()), true)
+	}
+
+	return c.sendPayload(MessagePayload{Embeds: []Embed{embed}})
+}
+
+// statusText renders a status for an embed field, which can't be empty
+func statusText(status string) string {
+	if status == "" {
+		return "*(empty)*"
+	}
+	return status
+}
+
 // SendSuccessMessage sends a success message with embed styling
 func (c *WebhookClient) SendSuccessMessage(title, description string) error {
 	payload := MessagePayload{
@@ -88,7 +329,7 @@ func (c *WebhookClient) SendSuccessMessage(title, description string) error {
 				Title:       title,
 				Description: description,
 				Color:       0x00FF00, // Green color for success
-				Timestamp:   time.Now().Format(time.RFC3339),
+				Timestamp:   c.timestamp(),
 				Footer: &Footer{
 					Text: "WhatsApp Profile Fetcher",
 				},
@@ -99,36 +340,242 @@ func (c *WebhookClient) SendSuccessMessage(title, description string) error {
 	return c.sendPayload(payload)
 }
 
+// SendHeartbeat announces that a long-running fetcher came online or shut
+// down, with the number of tracked targets and the version set by WithVersion
+func (c *WebhookClient) SendHeartbeat(online bool, tracked int) error {
+	embed := Embed{
+		Title:       "Fetcher Online",
+		Description: fmt.Sprintf("Tracking %d number(s)", tracked),
+		Color:       0x5865F2, // Blurple for informational updates
+		Timestamp:   c.timestamp(),
+		Footer: &Footer{
+			Text: "WhatsApp Profile Fetcher",
+		},
+	}
+	if !online {
+		embed.Title = "Fetcher Offline"
+		embed.Description = fmt.Sprintf("Shut down cleanly after tracking %d number(s)", tracked)
+		embed.Color = 0x95A5A6 // Grey once the fetcher has stopped
+	}
+	embed.AddField("Version", c.version, true)
+
+	return c.sendPayload(MessagePayload{Embeds: []Embed{embed}})
+}
+
+// SendSummary sends a single embed summarizing a batch run
+func (c *WebhookClient) SendSummary(result batch.FetchResult) error {
+	embed := c.summaryEmbed(result)
+	return c.sendPayload(MessagePayload{Embeds: []Embed{embed}})
+}
+
+// SendQuietSummary sends the summary embed of a run to confirm that nothing
+// has changed for quietFor, so a silent channel isn't mistaken for a stopped fetcher
+func (c *WebhookClient) SendQuietSummary(result batch.FetchResult, quietFor time.Duration) error {
+	embed := c.summaryEmbed(result)
+	embed.Title = "All Quiet"
+	embed.Description = fmt.Sprintf("No changes in the last %s; checked %d numbers in %s",
+		quietFor.Round(time.Minute), len(result.Items), result.Duration.Round(100*time.Millisecond))
+	return c.sendPayload(MessagePayload{Embeds: []Embed{embed}})
+}
+
+// summaryEmbed builds the embed summarizing a batch run
+func (c *WebhookClient) summaryEmbed(result batch.FetchResult) Embed {
+	failed := result.Failed()
+
+	color := 0x00FF00 // Green color when everything succeeded
+	if len(failed) > 0 {
+		color = 0xFFA500 // Orange color for partial failures
+	}
+
+	embed := Embed{
+		Title:       "Fetch Summary",
+		Description: fmt.Sprintf("Checked %d numbers in %s", len(result.Items), result.Duration.Round(100*time.Millisecond)),
+		Color:       color,
+		Timestamp:   c.timestamp(),
+		Footer: &Footer{
+			Text: "WhatsApp Profile Fetcher",
+		},
+	}
+	embed.AddField("Changed", strconv.Itoa(result.ChangedCount()), true)
+	embed.AddField("Failed", strconv.Itoa(len(failed)), true)
+	embed.AddField("Total Size", formatBytes(result.TotalBytes()), true)
+
+	// Spoiler tags keep a long error list collapsed until clicked. Numbers that
+	// failed the same way share one line.
+	if len(failed) > 0 {
+		var lines []string
+		for _, group := range result.ErrorGroups() {
+			lines = append(lines, fmt.Sprintf("%s (%s)", group, groupDetails(group)))
+		}
+		errorList := truncate(strings.Join(lines, "\n"), maxFieldValueLength-4)
+		embed.AddField("Errors", "||"+errorList+"||", false)
+	}
+	return embed
+}
+
+// groupDetails lists the numbers of an error group, with the correlation ID
+// when there is only one
+func groupDetails(group batch.ErrorGroup) string {
+	details := strings.Join(group.Numbers, ", ")
+	if group.Count() == 1 && len(group.CorrelationIDs) == 1 {
+		details += " [" + group.CorrelationIDs[0] + "]"
+	}
+	return details
+}
+
+// formatBytes renders a byte count for humans
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
 // SendImageWithFile sends an image file to Discord
 func (c *WebhookClient) SendImageWithFile(imageData []byte, filename, phoneNumber string) error {
-	// Create multipart form data
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
+	return c.SendImageWithFields(imageData, filename, phoneNumber, nil)
+}
+
+// SendImageWithFields sends an image file to Discord with extra embed fields
+func (c *WebhookClient) SendImageWithFields(imageData []byte, filename, phoneNumber string, fields []Field) error {
+	return c.SendProfileImage(ProfileImage{
+		Data:     imageData,
+		Filename: filename,
+		Number:   phoneNumber,
+		Fields:   fields,
+	})
+}
+
+// ProfileImage is a profile picture to post with SendProfileImage
+type ProfileImage struct {
+	Data     []byte
+	Filename string
+	Number   string
+	// Name is the contact name, if known
+	Name string
+	// Title and Description replace the rendered templates when set
+	Title       string
+	Description string
+	Fields      []Field
+}
 
-	// Add the image file
-	fileWriter, err := writer.CreateFormFile("file", filename)
+// SendImageReader sends an image read from r, which must yield size bytes, to
+// Discord. The image is streamed into the request instead of being held in
+// memory, which bounds memory use for large media.
+func (c *WebhookClient) SendImageReader(r io.Reader, size int64, filename, phoneNumber string) error {
+	payload, err := c.profilePayload(ProfileImage{Filename: filename, Number: phoneNumber}, int(size))
 	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
+		return err
 	}
+	return c.sendMultipart(payload, []attachment{{filename: filename, reader: r, size: size}})
+}
 
-	_, err = fileWriter.Write(imageData)
+// SendProfileImage sends a profile picture with its title and description
+// rendered from the configured templates unless the caller supplied them
+func (c *WebhookClient) SendProfileImage(image ProfileImage) error {
+	payload, err := c.profilePayload(image, len(image.Data))
 	if err != nil {
-		return fmt.Errorf("failed to write image data: %w", err)
+		return err
 	}
+	return c.sendMultipart(payload, []attachment{{filename: image.Filename, data: image.Data}})
+}
 
-	// Add the payload data
-	payloadWriter, err := writer.CreateFormField("payload_json")
+// profilePayload builds the message of SendProfileImage for an image of size bytes
+func (c *WebhookClient) profilePayload(image ProfileImage, size int) (MessagePayload, error) {
+	now := c.clock.Now()
+	title, description, err := c.templates.render(ImageTemplateData{
+		Number:    image.Number,
+		Name:      image.Name,
+		Timestamp: now,
+		ImageSize: size,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create payload field: %w", err)
+		return MessagePayload{}, err
+	}
+	if image.Title != "" {
+		title = truncate(image.Title, maxTitleLength)
+	}
+	if image.Description != "" {
+		description = truncate(image.Description, maxDescriptionLength)
+	}
+
+	embed := Embed{
+		Title:       title,
+		Description: description,
+		Color:       0x0099FF, // Blue color for info
+		Timestamp:   now.Format(time.RFC3339),
+		Footer: &Footer{
+			Text: "WhatsApp Profile Fetcher",
+		},
+	}
+	for _, field := range image.Fields {
+		embed.AddField(field.Name, field.Value, field.Inline)
+	}
+
+	return MessagePayload{
+		Embeds: []Embed{embed},
+	}, nil
+}
+
+// maxAttachments is the most files Discord accepts on one message
+const maxAttachments = 10
+
+// GalleryImage is one image posted by SendGallery
+type GalleryImage struct {
+	Filename string
+	Data     []byte
+	// Label names the image in the embed, e.g. "Alice (+1234567890)"
+	Label string
+}
+
+// SendGallery posts several images as one message with an embed listing their
+// labels. More than ten images are split over several messages.
+func (c *WebhookClient) SendGallery(images []GalleryImage) error {
+	for start := 0; start < len(images); start += maxAttachments {
+		chunk := images[start:min(start+maxAttachments, len(images))]
+
+		files := make([]attachment, 0, len(chunk))
+		lines := make([]string, 0, len(chunk))
+		for i, image := range chunk {
+			files = append(files, attachment{filename: image.Filename, data: image.Data})
+			lines = append(lines, fmt.Sprintf("%d. %s", start+i+1, image.Label))
+		}
+
+		payload := MessagePayload{
+			Embeds: []Embed{
+				{
+					Title:       "WhatsApp Profile Images",
+					Description: truncate(strings.Join(lines, "\n"), maxDescriptionLength),
+					Color:       0x0099FF, // Blue color for info
+					Timestamp:   c.timestamp(),
+					Footer: &Footer{
+						Text: fmt.Sprintf("WhatsApp Profile Fetcher • %d-%d of %d", start+1, start+len(chunk), len(images)),
+					},
+				},
+			},
+		}
+
+		if err := c.sendMultipart(payload, files); err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// SendFile sends an arbitrary file (e.g. a JSON report or a log) to Discord
+func (c *WebhookClient) SendFile(data []byte, filename, description string) error {
 	payload := MessagePayload{
 		Embeds: []Embed{
 			{
-				Title:       "WhatsApp Profile Image",
-				Description: fmt.Sprintf("Profile image for: %s", phoneNumber),
-				Color:       0x0099FF, // Blue color for info
-				Timestamp:   time.Now().Format(time.RFC3339),
+				Title:       filename,
+				Description: description,
+				Color:       0x808080, // Grey color for attachments
+				Timestamp:   c.timestamp(),
 				Footer: &Footer{
 					Text: "WhatsApp Profile Fetcher",
 				},
@@ -136,67 +583,259 @@ func (c *WebhookClient) SendImageWithFile(imageData []byte, filename, phoneNumbe
 		},
 	}
 
-	payloadJSON, err := json.Marshal(payload)
+	return c.sendMultipart(payload, []attachment{{filename: filename, data: data}})
+}
+
+// SendQRCode posts a pairing QR code image and returns the message ID so the
+// image can be replaced when the code rotates
+func (c *WebhookClient) SendQRCode(pngData []byte) (string, error) {
+	body, err := c.sendMultipartRequest(http.MethodPost, c.webhookURL+"?wait=true", qrPayload(c.timestamp()), []attachment{{filename: "qr.png", data: pngData}})
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return "", err
 	}
 
-	_, err = payloadWriter.Write(payloadJSON)
-	if err != nil {
-		return fmt.Errorf("failed to write payload: %w", err)
+	var message struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		return "", fmt.Errorf("failed to decode webhook response: %w", err)
 	}
+	return message.ID, nil
+}
 
-	err = writer.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close writer: %w", err)
+// UpdateQRCode replaces the image of a message posted by SendQRCode
+func (c *WebhookClient) UpdateQRCode(messageID string, pngData []byte) error {
+	payload := qrPayload(c.timestamp())
+	// Listing only the new upload drops the previous code
+	payload.Attachments = []AttachmentRef{{ID: 0}}
+
+	_, err := c.sendMultipartRequest(http.MethodPatch, c.webhookURL+"/messages/"+messageID, payload, []attachment{{filename: "qr.png", data: pngData}})
+	return err
+}
+
+// qrPayload builds the embed showing the pairing QR code
+func qrPayload(timestamp string) MessagePayload {
+	return MessagePayload{
+		Embeds: []Embed{
+			{
+				Title:       "WhatsApp Pairing",
+				Description: "Scan this code in WhatsApp under Settings → Linked Devices → Link a Device. It refreshes as WhatsApp rotates it.",
+				Color:       0x25D366, // WhatsApp green
+				Timestamp:   timestamp,
+				Image: &Image{
+					URL: "attachment://qr.png",
+				},
+				Footer: &Footer{
+					Text: "WhatsApp Profile Fetcher",
+				},
+			},
+		},
+	}
+}
+
+// attachment is a file uploaded alongside a webhook message. Its content is
+// data, or size bytes streamed from reader when reader is set.
+type attachment struct {
+	filename string
+	data     []byte
+	reader   io.Reader
+	size     int64
+}
+
+// sendMultipart sends a payload with file attachments as multipart form data
+func (c *WebhookClient) sendMultipart(payload MessagePayload, files []attachment) error {
+	_, err := c.sendMultipartRequest(http.MethodPost, c.webhookURL, payload, files)
+	return err
+}
+
+// sendMultipartRequest sends a multipart payload with the given method and URL
+// and returns the response body
+func (c *WebhookClient) sendMultipartRequest(method, url string, payload MessagePayload, files []attachment) ([]byte, error) {
+	payload = c.maskPayload(payload)
+	if c.masker != nil {
+		masked := make([]attachment, len(files))
+		for i, file := range files {
+			masked[i] = file
+			masked[i].filename = c.masker.Text(file.filename)
+		}
+		files = masked
 	}
 
-	// Send the request
-	req, err := http.NewRequest("POST", c.webhookURL, &buf)
+	fields, err := c.encoder.MultipartFields(payload)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+	// Streamed attachments are written into the request body as it is sent,
+	// so they can't be sent again; everything else is built in memory first
+	if streamed(files) {
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		go func() {
+			pw.CloseWithError(writeMultipart(writer, files, fields))
+		}()
+		defer pr.Close()
+
+		return c.do(func() (*http.Request, error) {
+			req, err := http.NewRequest(method, url, pr)
+			if err == nil {
+				req.Header.Set("Content-Type", writer.FormDataContentType())
+			}
+			return req, err
+		}, 1)
+	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+	var buf multipartBuffer = new(bytes.Buffer)
+	if c.newBuffer != nil {
+		buf = c.newBuffer()
 	}
-	defer resp.Body.Close()
+	writer := multipart.NewWriter(buf)
+	if err := writeMultipart(writer, files, fields); err != nil {
+		return nil, err
+	}
+	return c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(method, url, bytes.NewReader(buf.Bytes()))
+		if err == nil {
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+		}
+		return req, err
+	}, c.retryAttempts)
+}
 
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("discord webhook returned error: %d - %s", resp.StatusCode, string(body))
+// multipartBuffer holds a multipart body built in memory
+type multipartBuffer interface {
+	io.Writer
+	Bytes() []byte
+}
+
+// streamed reports whether any attachment is read from a reader
+func streamed(files []attachment) bool {
+	for _, file := range files {
+		if file.reader != nil {
+			return true
+		}
 	}
+	return false
+}
 
+// writeMultipart writes the files and payload fields as multipart form data
+// and closes writer
+func writeMultipart(writer *multipart.Writer, files []attachment, fields url.Values) error {
+	for i, file := range files {
+		data := file.data
+		var reader *bufio.Reader
+		if file.reader != nil {
+			// Sniff the content type from the start of the stream
+			reader = bufio.NewReader(file.reader)
+			data, _ = reader.Peek(512)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files[%d]"; filename="%s"`, i, escapeQuotes(file.filename)))
+		header.Set("Content-Type", contentType(file.filename, data))
+
+		fileWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return fmt.Errorf("failed to create form file: %w", err)
+		}
+
+		// Never post a truncated attachment; Discord's error for one is unhelpful
+		if reader != nil {
+			n, err := io.Copy(fileWriter, io.LimitReader(reader, file.size))
+			if err == nil && n != file.size {
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return fmt.Errorf("failed to stream file data for %s (%d of %d bytes written): %w", file.filename, n, file.size, err)
+			}
+			continue
+		}
+		n, err := fileWriter.Write(file.data)
+		if err == nil && n != len(file.data) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write file data for %s (%d of %d bytes written): %w", file.filename, n, len(file.data), err)
+		}
+	}
+
+	// Add the payload data
+	for name, values := range fields {
+		for _, value := range values {
+			if err := writer.WriteField(name, value); err != nil {
+				return fmt.Errorf("failed to write payload: %w", err)
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
 	return nil
 }
 
-// sendPayload sends a JSON payload to Discord
-func (c *WebhookClient) sendPayload(payload MessagePayload) error {
-	payloadJSON, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+// maskPayload returns a copy of payload with phone numbers masked in every
+// text the message shows, including attachment:// references so they keep
+// matching the masked filenames
+func (c *WebhookClient) maskPayload(payload MessagePayload) MessagePayload {
+	if c.masker == nil {
+		return payload
 	}
 
-	req, err := http.NewRequest("POST", c.webhookURL, bytes.NewBuffer(payloadJSON))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	payload.Content = c.masker.Text(payload.Content)
+	embeds := make([]Embed, len(payload.Embeds))
+	for i, embed := range payload.Embeds {
+		embed.Title = c.masker.Text(embed.Title)
+		embed.Description = c.masker.Text(embed.Description)
+		if embed.Footer != nil {
+			embed.Footer = &Footer{Text: c.masker.Text(embed.Footer.Text)}
+		}
+		if embed.Image != nil {
+			embed.Image = &Image{URL: c.masker.Text(embed.Image.URL)}
+		}
+		fields := make([]Field, len(embed.Fields))
+		for j, field := range embed.Fields {
+			fields[j] = Field{Name: c.masker.Text(field.Name), Value: c.masker.Text(field.Value), Inline: field.Inline}
+		}
+		embed.Fields = fields
+		embeds[i] = embed
 	}
+	payload.Embeds = embeds
+	return payload
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+// contentType picks the MIME type of an attachment from its extension, falling back to sniffing
+func contentType(filename string, data []byte) string {
+	if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+		return byExt
 	}
-	defer resp.Body.Close()
+	return http.DetectContentType(data)
+}
+
+// escapeQuotes escapes a filename for use in a Content-Disposition header
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
 
-	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("discord webhook returned error: %d - %s", resp.StatusCode, string(body))
+// timestamp returns the current time formatted for an embed
+func (c *WebhookClient) timestamp() string {
+	return c.clock.Now().Format(time.RFC3339)
+}
+
+// sendPayload sends a payload to the webhook using the configured encoder
+func (c *WebhookClient) sendPayload(payload MessagePayload) error {
+	payload = c.maskPayload(payload)
+	body, err := c.encoder.Encode(payload)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	_, err = c.do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", c.webhookURL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", c.encoder.ContentType())
+		}
+		return req, err
+	}, c.retryAttempts)
+	return err
 }
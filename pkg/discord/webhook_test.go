@@ -0,0 +1,105 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-web-wa/pkg/clock"
+)
+
+// captureWebhook serves a webhook that records the payload of each message,
+// from the JSON body or the payload_json field of a multipart upload
+func captureWebhook(t *testing.T) (*httptest.Server, *[]MessagePayload) {
+	t.Helper()
+
+	var payloads []MessagePayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw []byte
+		if err := r.ParseMultipartForm(1 << 20); err == nil {
+			raw = []byte(r.FormValue("payload_json"))
+		} else {
+			raw, _ = io.ReadAll(r.Body)
+		}
+		var payload MessagePayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			t.Errorf("invalid payload %q: %v", raw, err)
+		}
+		payloads = append(payloads, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, &payloads
+}
+
+func TestEmbedTimestampsUseClock(t *testing.T) {
+	now := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	clk := clock.InLocation{Clock: clock.NewFake(now), Location: jakarta}
+
+	tests := []struct {
+		name string
+		send func(c *WebhookClient) error
+	}{
+		{"image", func(c *WebhookClient) error {
+			return c.SendImageWithFile([]byte("image"), "profile.jpg", "+1234567890")
+		}},
+		{"error", func(c *WebhookClient) error {
+			return c.SendErrorMessage("Fetch Error", "failed")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, payloads := captureWebhook(t)
+			c := NewWebhookClient(server.URL, WithClock(clk))
+
+			if err := tt.send(c); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if len(*payloads) != 1 || len((*payloads)[0].Embeds) != 1 {
+				t.Fatalf("got payloads %+v, want one embed", *payloads)
+			}
+			if got, want := (*payloads)[0].Embeds[0].Timestamp, "2025-03-02T06:30:00+07:00"; got != want {
+				t.Errorf("timestamp = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// shortBuffer accepts at most limit bytes of any single write without
+// reporting an error, like a writer that silently drops data
+type shortBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *shortBuffer) Write(p []byte) (int, error) {
+	if len(p) > b.limit {
+		p = p[:b.limit]
+	}
+	return b.Buffer.Write(p)
+}
+
+func TestSendImageShortWrite(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c := NewWebhookClient(server.URL)
+	c.newBuffer = func() multipartBuffer { return &shortBuffer{limit: 1024} }
+	err := c.SendImageWithFile(bytes.Repeat([]byte{0xFF}, 4096), "profile.jpg", "+1234567890")
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("SendImageWithFile() error = %v, want io.ErrShortWrite", err)
+	}
+	if requests != 0 {
+		t.Errorf("sent %d requests after a short write, want none", requests)
+	}
+}
@@ -0,0 +1,52 @@
+package imageutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+const (
+	// startQuality is the first JPEG quality Compress tries
+	startQuality = 85
+	// minQuality is the lowest JPEG quality Compress goes down to
+	minQuality = 25
+	// qualityStep is how much the quality drops between attempts
+	qualityStep = 10
+)
+
+// Compress re-encodes the image in data as JPEG at decreasing quality until
+// it fits in maxBytes. Images that already fit are returned unchanged. When
+// even the lowest quality is too large, the smallest encoding is returned and
+// the caller can compare its size against maxBytes. Transparent areas of
+// non-JPEG images are flattened onto white.
+func Compress(data []byte, maxBytes int) ([]byte, error) {
+	if len(data) <= maxBytes {
+		return data, nil
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if format != "jpeg" {
+		flat := image.NewRGBA(src.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), src, src.Bounds().Min, draw.Over)
+		src = flat
+	}
+
+	var buf bytes.Buffer
+	for quality := startQuality; ; quality -= qualityStep {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: max(quality, minQuality)}); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		if buf.Len() <= maxBytes || quality <= minQuality {
+			return buf.Bytes(), nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package imageutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"unicode"
+)
+
+// Corner is where a watermark is placed
+type Corner int
+
+// Watermark positions
+const (
+	BottomRight Corner = iota
+	BottomLeft
+	TopRight
+	TopLeft
+)
+
+// ParseCorner parses "bottom-right", "bottom-left", "top-right" or "top-left"
+func ParseCorner(s string) (Corner, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "bottom-right":
+		return BottomRight, nil
+	case "bottom-left":
+		return BottomLeft, nil
+	case "top-right":
+		return TopRight, nil
+	case "top-left":
+		return TopLeft, nil
+	default:
+		return 0, fmt.Errorf("unknown corner %q (use bottom-right, bottom-left, top-right or top-left)", s)
+	}
+}
+
+// Watermark stamps text onto the image in data at the given corner, in white
+// on a translucent dark box so it stays readable on any picture. The text is
+// scaled to the image size. JPEG input stays JPEG; anything else becomes PNG.
+func Watermark(data []byte, text string, corner Corner) ([]byte, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+
+	// One font dot per ~160 pixels of width, with a one-dot gap between glyphs
+	runes := []rune(text)
+	scale := max(1, bounds.Dx()/160)
+	padding := 2 * scale
+	textWidth := (len(runes)*(glyphWidth+1) - 1) * scale
+	textHeight := glyphHeight * scale
+	boxWidth, boxHeight := textWidth+2*padding, textHeight+2*padding
+	margin := 2 * scale
+
+	var box image.Rectangle
+	switch corner {
+	case TopLeft:
+		box = image.Rect(bounds.Min.X+margin, bounds.Min.Y+margin, bounds.Min.X+margin+boxWidth, bounds.Min.Y+margin+boxHeight)
+	case TopRight:
+		box = image.Rect(bounds.Max.X-margin-boxWidth, bounds.Min.Y+margin, bounds.Max.X-margin, bounds.Min.Y+margin+boxHeight)
+	case BottomLeft:
+		box = image.Rect(bounds.Min.X+margin, bounds.Max.Y-margin-boxHeight, bounds.Min.X+margin+boxWidth, bounds.Max.Y-margin)
+	default:
+		box = image.Rect(bounds.Max.X-margin-boxWidth, bounds.Max.Y-margin-boxHeight, bounds.Max.X-margin, bounds.Max.Y-margin)
+	}
+
+	draw.Draw(img, box, image.NewUniform(color.NRGBA{0, 0, 0, 0x99}), image.Point{}, draw.Over)
+	white := image.NewUniform(color.White)
+	origin := box.Min.Add(image.Pt(padding, padding))
+	for i, r := range runes {
+		glyph, ok := glyphs[unicode.ToUpper(r)]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		x0 := origin.X + i*(glyphWidth+1)*scale
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				dot := image.Rect(x0+col*scale, origin.Y+row*scale, x0+(col+1)*scale, origin.Y+(row+1)*scale)
+				draw.Draw(img, dot, white, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode watermarked image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-web-wa/pkg/correlation"
+	"go-web-wa/pkg/mask"
+)
+
+// masker hides phone numbers in every record once SetMasker is called
+var masker atomic.Pointer[mask.Masker]
+
+// location is the time zone of record timestamps once SetLocation is called
+var location atomic.Pointer[time.Location]
+
+// SetMasker masks phone numbers in the message and string attributes of all
+// later log records; nil turns masking off
+func SetMasker(m *mask.Masker) {
+	masker.Store(m)
+}
+
+// levelAliases maps level names common in other tools to slog's names
+var levelAliases = map[string]string{
+	"trace":    "debug",
+	"verbose":  "debug",
+	"notice":   "info",
+	"warning":  "warn",
+	"err":      "error",
+	"fatal":    "error",
+	"critical": "error",
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error", case-insensitively,
+// and accepts common aliases such as "warning" and "verbose"
+func ParseLevel(level string) (slog.Level, error) {
+	name := strings.ToLower(strings.TrimSpace(level))
+	if alias, ok := levelAliases[name]; ok {
+		name = alias
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: use debug, info, warn or error", level)
+	}
+	return lvl, nil
+}
+
+// SetLocation writes the timestamps of all later log records in loc; nil
+// keeps the system's local time
+func SetLocation(loc *time.Location) {
+	location.Store(loc)
+}
+
+// Setup installs the default slog logger writing to w in the given format
+// ("text" or "json") at the given level (see ParseLevel).
+// slog.SetDefault routes plain log.Printf lines through it at info level.
+func Setup(w io.Writer, format, level string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q: use text or json", format)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// contextHandler adds the correlation ID and fields carried by the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the context attributes, masks phone numbers when enabled and
+// passes the record on
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := correlation.FromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("correlation_id", id))
+	}
+	record.AddAttrs(correlation.Fields(ctx)...)
+	if loc := location.Load(); loc != nil {
+		record.Time = record.Time.In(loc)
+	}
+	if m := masker.Load(); m != nil {
+		record = maskRecord(m, record)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// maskRecord copies record with phone numbers masked in its message and attributes
+func maskRecord(m *mask.Masker, record slog.Record) slog.Record {
+	masked := slog.NewRecord(record.Time, record.Level, m.Text(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		masked.AddAttrs(maskAttr(m, attr))
+		return true
+	})
+	return masked
+}
+
+// maskAttr masks string values, including those of errors and Stringers such as JIDs
+func maskAttr(m *mask.Masker, attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, m.Text(value.String()))
+	case slog.KindGroup:
+		attrs := value.Group()
+		maskedAttrs := make([]any, len(attrs))
+		for i, a := range attrs {
+			maskedAttrs[i] = maskAttr(m, a)
+		}
+		return slog.Group(attr.Key, maskedAttrs...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(attr.Key, m.Text(v.Error()))
+		case fmt.Stringer:
+			return slog.String(attr.Key, m.Text(v.String()))
+		}
+	}
+	return attr
+}
+
+// WithAttrs keeps the context handling on derived handlers
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handling on derived handlers
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
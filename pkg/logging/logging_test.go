@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{"verbose", slog.LevelDebug},
+		{"TRACE", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{" Warning ", slog.LevelWarn},
+		{"warn", slog.LevelWarn},
+		{"fatal", slog.LevelError},
+		{"error", slog.LevelError},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.level)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", tt.level, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error(`ParseLevel("loud") succeeded, want an error`)
+	}
+}
+
+func TestSetLocation(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		SetLocation(nil)
+		slog.SetDefault(defaultLogger)
+	})
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "json", "info"); err != nil {
+		t.Fatal(err)
+	}
+	SetLocation(time.FixedZone("WIB", 7*60*60))
+	slog.Info("hello")
+
+	if !strings.Contains(buf.String(), "+07:00") {
+		t.Errorf("record = %s, want the time in +07:00", buf.String())
+	}
+}
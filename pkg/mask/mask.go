@@ -0,0 +1,116 @@
+// Package mask hides phone numbers in log lines and notifications
+package mask
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	// minDigits and maxDigits bound the digit runs treated as phone numbers
+	minDigits = 7
+	maxDigits = 15
+	// keepLeading and keepTrailing are the digits left visible, as in +62****1234
+	keepLeading  = 2
+	keepTrailing = 4
+)
+
+// digitRun finds candidate numbers; whether one is masked depends on its context
+var digitRun = regexp.MustCompile(`\d+`)
+
+// Masker hides phone numbers in text. A nil Masker leaves text unchanged.
+type Masker struct {
+	known map[string]bool
+}
+
+// New returns a Masker that hides the given numbers wherever they appear, as
+// well as any number written with a leading + and the user part of JIDs
+func New(numbers ...string) *Masker {
+	m := &Masker{known: make(map[string]bool)}
+	for _, number := range numbers {
+		if user, _, found := strings.Cut(number, "@"); found {
+			number, _, _ = strings.Cut(user, ":")
+		}
+		if digits := onlyDigits(number); len(digits) >= minDigits {
+			m.known[digits] = true
+		}
+	}
+	return m
+}
+
+// Text returns s with every phone number masked. A run of digits counts as a
+// number when it follows a +, is the user part of a JID (followed by @ or a
+// :device@ suffix) or is one of the known numbers; other digits such as
+// picture IDs and timestamps are kept, and so are numbers inside links other
+// than attachment:// references, which would otherwise stop working.
+func (m *Masker) Text(s string) string {
+	if m == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range digitRun.FindAllStringIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		digits := s[start:end]
+		if len(digits) < minDigits || len(digits) > maxDigits {
+			continue
+		}
+		if !m.known[digits] && !(start > 0 && s[start-1] == '+') && !isJIDUser(s[end:]) {
+			continue
+		}
+		if inURL(s[:start]) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Number(digits))
+		last = end
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// inURL reports whether the text before a run of digits ends inside a link
+func inURL(before string) bool {
+	token := before[strings.LastIndexAny(before, " \t\n(<\"'")+1:]
+	return strings.Contains(token, "://") && !strings.HasPrefix(token, "attachment://")
+}
+
+// isJIDUser reports whether rest, the text after a run of digits, continues
+// a JID: @server or :device@server
+func isJIDUser(rest string) bool {
+	if strings.HasPrefix(rest, "@") {
+		return true
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return false
+	}
+	device := strings.TrimLeft(rest[1:], "0123456789")
+	return len(device) < len(rest)-1 && strings.HasPrefix(device, "@")
+}
+
+// Number masks a single number, keeping a leading +
+func Number(number string) string {
+	prefix := ""
+	if strings.HasPrefix(number, "+") {
+		prefix = "+"
+	}
+	digits := onlyDigits(number)
+	if len(digits) <= keepLeading+keepTrailing {
+		return prefix + "****"
+	}
+	return prefix + digits[:keepLeading] + "****" + digits[len(digits)-keepTrailing:]
+}
+
+// onlyDigits drops everything but the digits of s
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
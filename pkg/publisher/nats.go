@@ -0,0 +1,199 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// NATSPublisher publishes over the NATS text protocol. Each publish is
+// followed by a PING so errors surface before Publish returns.
+type NATSPublisher struct {
+	addr  string
+	user  string
+	pass  string
+	token string
+	// tls upgrades the connection even when the server doesn't require it
+	tls       bool
+	tlsConfig *tls.Config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// natsInfo is the part of the server INFO message we care about
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// natsConnect is the CONNECT message sent after the server greeting
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// NewNATS creates a publisher for a nats://[user:pass@|token@]host[:port] URL.
+// The tls:// scheme always uses TLS; nats:// uses it when the server requires it.
+func NewNATS(u *url.URL) (*NATSPublisher, error) {
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported NATS URL scheme %q (use nats or tls)", u.Scheme)
+	}
+
+	p := &NATSPublisher{addr: hostPort(u, "4222"), tls: u.Scheme == "tls"}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			p.user = u.User.Username()
+			p.pass = pass
+		} else {
+			p.token = u.User.Username()
+		}
+	}
+	return p, nil
+}
+
+// Publish sends payload on subject, reconnecting once if the connection was lost
+func (p *NATSPublisher) Publish(ctx context.Context, subject string, payload []byte) error {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid NATS subject %q", subject)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.publishLocked(ctx, subject, payload)
+	if err != nil && p.conn != nil && ctx.Err() == nil {
+		p.closeLocked()
+		err = p.publishLocked(ctx, subject, payload)
+	}
+	if err != nil {
+		p.closeLocked()
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+	return nil
+}
+
+// publishLocked connects if needed and sends one message; the caller must hold p.mu
+func (p *NATSPublisher) publishLocked(ctx context.Context, subject string, payload []byte) error {
+	if p.conn == nil {
+		if err := p.connectLocked(ctx); err != nil {
+			return err
+		}
+	}
+
+	p.conn.SetDeadline(deadline(ctx))
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload)
+	if _, err := p.conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return p.waitForPongLocked()
+}
+
+// connectLocked dials the server and completes the handshake, upgrading to
+// TLS after the server greeting; the caller must hold p.mu
+func (p *NATSPublisher) connectLocked(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", p.addr, err)
+	}
+	conn.SetDeadline(deadline(ctx))
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+
+	line, err := p.readLineLocked()
+	if err != nil {
+		return fmt.Errorf("failed to read server info: %w", err)
+	}
+	infoJSON, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("unexpected greeting from NATS server: %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(infoJSON), &info); err != nil {
+		return fmt.Errorf("failed to parse server info: %w", err)
+	}
+	if p.tls || info.TLSRequired {
+		tlsConn := tls.Client(conn, tlsConfigFor(p.tlsConfig, p.addr))
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("TLS handshake with %s failed: %w", p.addr, err)
+		}
+		p.conn = tlsConn
+		p.reader = bufio.NewReader(tlsConn)
+	}
+
+	options, err := json.Marshal(natsConnect{
+		Name:  "go-web-wa",
+		Lang:  "go",
+		User:  p.user,
+		Pass:  p.pass,
+		Token: p.token,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal connect options: %w", err)
+	}
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nPING\r\n", options); err != nil {
+		return fmt.Errorf("failed to send connect: %w", err)
+	}
+	return p.waitForPongLocked()
+}
+
+// waitForPongLocked reads until the server answers our PING, answering its
+// PINGs and returning any -ERR it sends; the caller must hold p.mu
+func (p *NATSPublisher) waitForPongLocked() error {
+	for {
+		line, err := p.readLineLocked()
+		if err != nil {
+			return fmt.Errorf("read failed: %w", err)
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("write failed: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// readLineLocked reads one protocol line without the trailing CRLF
+func (p *NATSPublisher) readLineLocked() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Close closes the connection to the server
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closeLocked()
+}
+
+// closeLocked drops the current connection; the caller must hold p.mu
+func (p *NATSPublisher) closeLocked() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	p.reader = nil
+	return err
+}
@@ -0,0 +1,221 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS speaks enough of the NATS protocol to accept publishes
+type fakeNATS struct {
+	ln net.Listener
+	// tlsConfig makes the server require TLS when set
+	tlsConfig *tls.Config
+	// pubErr is sent as -ERR in reply to every PUB when set
+	pubErr string
+	// dropAfterPub closes the connection after each message
+	dropAfterPub bool
+
+	mu       sync.Mutex
+	connects []natsConnect
+	messages []string
+	conns    int
+}
+
+// startFakeNATS starts serving s, which holds the options of the server
+func startFakeNATS(t *testing.T, s *fakeNATS) *fakeNATS {
+	s.ln = listen(t)
+	go s.serve()
+	return s
+}
+
+func (s *fakeNATS) url(userinfo string) *url.URL {
+	u := &url.URL{Scheme: "nats", Host: s.ln.Addr().String()}
+	if userinfo != "" {
+		u.User = url.User(userinfo)
+	}
+	return u
+}
+
+func (s *fakeNATS) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeNATS) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"tls_required\":%t}\r\n", s.tlsConfig != nil)
+	if s.tlsConfig != nil {
+		conn = tls.Server(conn, s.tlsConfig)
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch verb, args, _ := strings.Cut(line, " "); verb {
+		case "CONNECT":
+			var options natsConnect
+			json.Unmarshal([]byte(args), &options)
+			s.mu.Lock()
+			s.connects = append(s.connects, options)
+			s.mu.Unlock()
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "PUB":
+			subject, size, _ := strings.Cut(args, " ")
+			n, _ := strconv.Atoi(size)
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			if s.pubErr != "" {
+				fmt.Fprintf(conn, "-ERR '%s'\r\n", s.pubErr)
+				continue
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, subject+" "+string(payload[:n]))
+			s.mu.Unlock()
+			if s.dropAfterPub {
+				// Answer the trailing PING first so the publish itself succeeds
+				reader.ReadString('\n')
+				fmt.Fprint(conn, "PONG\r\n")
+				return
+			}
+		}
+	}
+}
+
+func TestNATSPublish(t *testing.T) {
+	server := startFakeNATS(t, &fakeNATS{})
+	p, err := NewNATS(server.url("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	for _, payload := range []string{`{"number":"+1"}`, `{"number":"+2"}`} {
+		if err := p.Publish(ctx, "pictures", []byte(payload)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	want := []string{`pictures {"number":"+1"}`, `pictures {"number":"+2"}`}
+	if fmt.Sprint(server.messages) != fmt.Sprint(want) {
+		t.Errorf("messages = %q, want %q", server.messages, want)
+	}
+	if server.conns != 1 {
+		t.Errorf("connections = %d, want the connection reused", server.conns)
+	}
+	if len(server.connects) != 1 || server.connects[0].Token != "secret" {
+		t.Errorf("CONNECT = %+v, want auth_token secret", server.connects)
+	}
+}
+
+func TestNATSPublishReconnects(t *testing.T) {
+	server := startFakeNATS(t, &fakeNATS{dropAfterPub: true})
+	p, err := NewNATS(server.url(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := range 2 {
+		if err := p.Publish(context.Background(), "pictures", []byte("event")); err != nil {
+			t.Fatalf("Publish() #%d error = %v", i+1, err)
+		}
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 2 || server.conns != 2 {
+		t.Errorf("got %d messages over %d connections, want 2 over 2", len(server.messages), server.conns)
+	}
+}
+
+func TestNATSPublishServerError(t *testing.T) {
+	server := startFakeNATS(t, &fakeNATS{pubErr: "Permissions Violation for Publish to pictures"})
+	p, err := NewNATS(server.url(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	err = p.Publish(context.Background(), "pictures", []byte("event"))
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Fatalf("Publish() error = %v, want the server error", err)
+	}
+}
+
+func TestNATSUpgradesToTLS(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := startFakeNATS(t, &fakeNATS{tlsConfig: serverTLS})
+	p, err := NewNATS(server.url(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.tlsConfig = clientTLS
+	defer p.Close()
+
+	if err := p.Publish(context.Background(), "pictures", []byte("event")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.messages) != 1 {
+		t.Errorf("messages = %q, want one", server.messages)
+	}
+}
+
+func TestNATSPublishStopsAtDeadline(t *testing.T) {
+	// A server that accepts but never greets
+	ln := listen(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	p, err := NewNATS(&url.URL{Scheme: "nats", Host: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := p.Publish(ctx, "pictures", []byte("event")); err == nil {
+		t.Fatal("Publish() succeeded against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Publish() returned after %v, want it bounded by the context", elapsed)
+	}
+}
@@ -0,0 +1,91 @@
+package publisher
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// DefaultSubject is the subject or channel change events are published on
+const DefaultSubject = "whatsapp.profile_picture.changed"
+
+// dialTimeout bounds connecting to the message bus and each request on it
+const dialTimeout = 10 * time.Second
+
+// Publisher sends messages to a message bus
+type Publisher interface {
+	// Publish sends payload on subject, giving up when ctx is done
+	Publish(ctx context.Context, subject string, payload []byte) error
+	// Close releases the connection to the bus
+	Close() error
+}
+
+// ChangeEvent is the JSON payload published when a profile picture changes
+type ChangeEvent struct {
+	Number        string    `json:"number"`
+	PictureID     string    `json:"picture_id,omitempty"`
+	Hash          string    `json:"hash"`
+	PreviousHash  string    `json:"previous_hash,omitempty"`
+	Size          int       `json:"size"`
+	DetectedAt    time.Time `json:"detected_at"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+// New returns the publisher for backend ("nats" or "redis") connected to
+// rawURL. The connection is made on the first Publish, so the bus doesn't
+// have to be up when the daemon starts.
+func New(backend, rawURL string) (Publisher, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publisher URL: %w", err)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("publisher URL %q has no host", rawURL)
+	}
+
+	switch backend {
+	case "nats":
+		return NewNATS(parsed)
+	case "redis":
+		return NewRedis(parsed)
+	default:
+		return nil, fmt.Errorf("unsupported publisher %q (use nats or redis)", backend)
+	}
+}
+
+// deadline returns when a request on the bus must finish: the deadline of ctx,
+// but no later than dialTimeout from now
+func deadline(ctx context.Context) time.Time {
+	limit := time.Now().Add(dialTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(limit) {
+		return d
+	}
+	return limit
+}
+
+// tlsConfigFor returns base (or a default config) set up to verify addr
+func tlsConfigFor(base *tls.Config, addr string) *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		cfg.ServerName = host
+	}
+	return cfg
+}
+
+// hostPort returns the host of u with defaultPort added when it has none
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return u.Hostname() + ":" + defaultPort
+}
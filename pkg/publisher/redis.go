@@ -0,0 +1,151 @@
+package publisher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// RedisPublisher publishes with the Redis PUBLISH command over RESP
+type RedisPublisher struct {
+	addr     string
+	username string
+	password string
+	// tls is set for rediss:// URLs
+	tls       bool
+	tlsConfig *tls.Config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedis creates a publisher for a redis://[[user]:pass@]host[:port] URL,
+// or a rediss:// URL to connect over TLS
+func NewRedis(u *url.URL) (*RedisPublisher, error) {
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("unsupported Redis URL scheme %q (use redis or rediss)", u.Scheme)
+	}
+
+	p := &RedisPublisher{addr: hostPort(u, "6379"), tls: u.Scheme == "rediss"}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			p.username = u.User.Username()
+			p.password = pass
+		} else {
+			p.password = u.User.Username()
+		}
+	}
+	return p, nil
+}
+
+// Publish sends payload on the channel named subject, reconnecting once if
+// the connection was lost
+func (p *RedisPublisher) Publish(ctx context.Context, subject string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.publishLocked(ctx, subject, payload)
+	if err != nil && p.conn != nil && ctx.Err() == nil {
+		p.closeLocked()
+		err = p.publishLocked(ctx, subject, payload)
+	}
+	if err != nil {
+		p.closeLocked()
+		return fmt.Errorf("failed to publish to Redis: %w", err)
+	}
+	return nil
+}
+
+// publishLocked connects if needed and runs PUBLISH; the caller must hold p.mu
+func (p *RedisPublisher) publishLocked(ctx context.Context, subject string, payload []byte) error {
+	if p.conn == nil {
+		if err := p.connectLocked(ctx); err != nil {
+			return err
+		}
+	}
+	_, err := p.commandLocked(ctx, []byte("PUBLISH"), []byte(subject), payload)
+	return err
+}
+
+// connectLocked dials the server and authenticates; the caller must hold p.mu
+func (p *RedisPublisher) connectLocked(ctx context.Context) error {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if p.tls {
+		tlsDialer := tls.Dialer{NetDialer: dialer, Config: tlsConfigFor(p.tlsConfig, p.addr)}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", p.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", p.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", p.addr, err)
+	}
+	p.conn = conn
+	p.reader = bufio.NewReader(conn)
+
+	if p.password == "" {
+		return nil
+	}
+
+	args := [][]byte{[]byte("AUTH")}
+	if p.username != "" {
+		args = append(args, []byte(p.username))
+	}
+	args = append(args, []byte(p.password))
+	if _, err := p.commandLocked(ctx, args...); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	return nil
+}
+
+// commandLocked sends a command and returns its single-line reply; the caller must hold p.mu
+func (p *RedisPublisher) commandLocked(ctx context.Context, args ...[]byte) (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&buf, "$%d\r\n", len(arg))
+		buf.Write(arg)
+		buf.WriteString("\r\n")
+	}
+
+	p.conn.SetDeadline(deadline(ctx))
+	if _, err := p.conn.Write(buf.Bytes()); err != nil {
+		return "", fmt.Errorf("write failed: %w", err)
+	}
+
+	line, err := p.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("read failed: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if reply, ok := strings.CutPrefix(line, "-"); ok {
+		return "", fmt.Errorf("server error: %s", reply)
+	}
+	return line, nil
+}
+
+// Close closes the connection to the server
+func (p *RedisPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closeLocked()
+}
+
+// closeLocked drops the current connection; the caller must hold p.mu
+func (p *RedisPublisher) closeLocked() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	p.reader = nil
+	return err
+}
@@ -0,0 +1,156 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis answers AUTH and PUBLISH over RESP
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T, password string, tlsConfig *tls.Config) *fakeRedis {
+	s := &fakeRedis{ln: listen(t), password: password}
+	if tlsConfig != nil {
+		s.ln = tls.NewListener(s.ln, tlsConfig)
+	}
+	go s.serve()
+	return s
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+
+		switch {
+		case args[0] == "AUTH" && args[len(args)-1] == s.password:
+			authed = true
+			fmt.Fprint(conn, "+OK\r\n")
+		case args[0] == "AUTH":
+			fmt.Fprint(conn, "-WRONGPASS invalid username-password pair or user is disabled.\r\n")
+		case !authed:
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "PUBLISH":
+			fmt.Fprint(conn, ":1\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+// readCommand reads one RESP array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func TestRedisPublish(t *testing.T) {
+	server := newFakeRedis(t, "secret", nil)
+	p, err := NewRedis(&url.URL{Scheme: "redis", Host: server.ln.Addr().String(), User: url.UserPassword("bot", "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Publish(context.Background(), "pictures", []byte("line one\r\nline two")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	want := [][]string{{"AUTH", "bot", "secret"}, {"PUBLISH", "pictures", "line one\r\nline two"}}
+	if fmt.Sprintf("%q", server.commands) != fmt.Sprintf("%q", want) {
+		t.Errorf("commands = %q, want %q", server.commands, want)
+	}
+}
+
+func TestRedisPublishAuthFailure(t *testing.T) {
+	server := newFakeRedis(t, "secret", nil)
+	p, err := NewRedis(&url.URL{Scheme: "redis", Host: server.ln.Addr().String(), User: url.User("wrong")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	err = p.Publish(context.Background(), "pictures", []byte("event"))
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("Publish() error = %v, want the authentication error", err)
+	}
+}
+
+func TestRedisPublishOverTLS(t *testing.T) {
+	serverTLS, clientTLS := testTLSConfigs(t)
+	server := newFakeRedis(t, "", serverTLS)
+	p, err := NewRedis(&url.URL{Scheme: "rediss", Host: server.ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.tlsConfig = clientTLS
+	defer p.Close()
+
+	if err := p.Publish(context.Background(), "pictures", []byte("event")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.commands) != 1 || server.commands[0][0] != "PUBLISH" {
+		t.Errorf("commands = %q, want a single PUBLISH", server.commands)
+	}
+}
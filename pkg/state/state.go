@@ -0,0 +1,260 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// downloadValidatorsTTL is how long validators are kept; WhatsApp's signed CDN
+// URLs expire well before that, so older entries can't be used again
+const downloadValidatorsTTL = 30 * 24 * time.Hour
+
+// Store persists change-detection state to a JSON file
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data Data
+}
+
+// Data is the on-disk representation of the state file
+type Data struct {
+	// Numbers holds per-target state keyed by phone number
+	Numbers map[string]*NumberState `json:"numbers"`
+	// Hashes maps the SHA-256 of stored image content to its object path
+	Hashes map[string]string `json:"hashes"`
+	// LastImage is the most recently fetched image, if it was cached
+	LastImage *LastImage `json:"last_image,omitempty"`
+	// Downloads holds the HTTP validators of downloaded images keyed by URL
+	Downloads map[string]*DownloadValidators `json:"downloads,omitempty"`
+}
+
+// DownloadValidators are the ETag and Last-Modified a CDN returned for an image URL
+type DownloadValidators struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	StoredAt     time.Time `json:"stored_at"`
+}
+
+// LastImage describes the cached copy of the most recently fetched image
+type LastImage struct {
+	Number    string    `json:"number"`
+	Filename  string    `json:"filename"`
+	Path      string    `json:"path"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// NumberState is what we remember about a single target
+type NumberState struct {
+	// LastFetched is when the profile picture was last fetched successfully
+	LastFetched time.Time `json:"last_fetched,omitzero"`
+	// LastNotified is when the profile picture was last posted
+	LastNotified time.Time `json:"last_notified,omitzero"`
+	// ChangeNotified is when a new profile picture was last posted; it starts
+	// the CHANGE_COOLDOWN_SECONDS window
+	ChangeNotified time.Time `json:"change_notified,omitzero"`
+	// LastHash is the SHA-256 of the most recently fetched image
+	LastHash string `json:"last_hash,omitempty"`
+	// PictureID is the WhatsApp ID of the most recently fetched picture
+	PictureID string `json:"picture_id,omitempty"`
+	// PictureFirstSeen is when PictureID was first observed, approximating when the picture changed
+	PictureFirstSeen time.Time `json:"picture_first_seen,omitzero"`
+	// PictureRemovedAt is when the contact was found to have removed their picture
+	PictureRemovedAt time.Time `json:"picture_removed_at,omitzero"`
+	// Status is the most recently seen "about" text
+	Status string `json:"status,omitempty"`
+	// StatusCheckedAt is when Status was last fetched; zero until the first check
+	StatusCheckedAt time.Time `json:"status_checked_at,omitzero"`
+	// StatusChangedAt is when a change of Status was last detected
+	StatusChangedAt time.Time `json:"status_changed_at,omitzero"`
+	// ConsecutiveFailures counts fetches that failed in a row since the last success
+	ConsecutiveFailures int `json:"consecutive_failures,omitempty"`
+	// RetryAfter is when a failing number may be fetched again in --watch mode
+	RetryAfter time.Time `json:"retry_after,omitzero"`
+	// FailureAlerted is true once the repeated failures were reported
+	FailureAlerted bool `json:"failure_alerted,omitempty"`
+	// History lists the detected picture changes, oldest first
+	History []PictureChange `json:"history,omitempty"`
+}
+
+// PictureChange is one entry in a number's picture history
+type PictureChange struct {
+	ChangedAt time.Time `json:"changed_at"`
+	PictureID string    `json:"picture_id,omitempty"`
+	Hash      string    `json:"hash"`
+	// Stored is the URL of the stored image, or its object path when the
+	// backend has no URLs; empty when storage is disabled
+	Stored string `json:"stored,omitempty"`
+}
+
+// Open loads the state file at path, starting empty if it doesn't exist yet
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: Data{
+			Numbers:   make(map[string]*NumberState),
+			Hashes:    make(map[string]string),
+			Downloads: make(map[string]*DownloadValidators),
+		},
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if s.data.Numbers == nil {
+		s.data.Numbers = make(map[string]*NumberState)
+	}
+	if s.data.Hashes == nil {
+		s.data.Hashes = make(map[string]string)
+	}
+	if s.data.Downloads == nil {
+		s.data.Downloads = make(map[string]*DownloadValidators)
+	}
+
+	return s, nil
+}
+
+// Path returns the location of the state file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Number returns a copy of the state for a phone number
+func (s *Store) Number(number string) NumberState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ns, ok := s.data.Numbers[number]; ok {
+		return *ns
+	}
+	return NumberState{}
+}
+
+// UpdateNumber applies fn to the state for a phone number and saves the state
+func (s *Store) UpdateNumber(number string, fn func(*NumberState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.data.Numbers[number]
+	if !ok {
+		ns = &NumberState{}
+		s.data.Numbers[number] = ns
+	}
+	fn(ns)
+
+	return s.saveLocked()
+}
+
+// Export writes the whole state as indented JSON to w
+func (s *Store) Export(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.data); err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	return nil
+}
+
+// LastImage returns the most recently cached image, if any
+func (s *Store) LastImage() (LastImage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.LastImage == nil {
+		return LastImage{}, false
+	}
+	return *s.data.LastImage, true
+}
+
+// SetLastImage records the most recently cached image and saves the state
+func (s *Store) SetLastImage(img LastImage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.LastImage = &img
+	return s.saveLocked()
+}
+
+// ObjectForHash returns the object path previously stored for a content hash
+func (s *Store) ObjectForHash(hash string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objectPath, ok := s.data.Hashes[hash]
+	return objectPath, ok
+}
+
+// RecordObject remembers the object path for a content hash and saves the state
+func (s *Store) RecordObject(hash, objectPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Hashes[hash] = objectPath
+	return s.saveLocked()
+}
+
+// DownloadValidators returns the ETag and Last-Modified stored for an image URL
+func (s *Store) DownloadValidators(url string) (etag, lastModified string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.data.Downloads[url]; ok {
+		return v.ETag, v.LastModified
+	}
+	return "", ""
+}
+
+// SetDownloadValidators remembers the validators of an image URL, drops
+// expired entries and saves the state
+func (s *Store) SetDownloadValidators(url, etag, lastModified string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, v := range s.data.Downloads {
+		if now.Sub(v.StoredAt) > downloadValidatorsTTL {
+			delete(s.data.Downloads, key)
+		}
+	}
+	s.data.Downloads[url] = &DownloadValidators{ETag: etag, LastModified: lastModified, StoredAt: now}
+	return s.saveLocked()
+}
+
+// saveLocked writes the state atomically; the caller must hold s.mu
+func (s *Store) saveLocked() error {
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
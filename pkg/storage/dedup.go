@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// HashIndex maps content hashes to the object path holding that content
+type HashIndex interface {
+	ObjectForHash(hash string) (string, bool)
+	RecordObject(hash, objectPath string) error
+}
+
+// Object describes a stored image
+type Object struct {
+	Path string
+	URL  string
+	Hash string
+	// Deduplicated is true when an existing object was reused instead of uploading
+	Deduplicated bool
+}
+
+// Uploader stores objects while skipping uploads of content that is already stored
+type Uploader struct {
+	backend Backend
+	index   HashIndex
+}
+
+// NewUploader creates an uploader that deduplicates by SHA-256 content hash
+func NewUploader(backend Backend, index HashIndex) *Uploader {
+	return &Uploader{
+		backend: backend,
+		index:   index,
+	}
+}
+
+// Upload stores data at objectPath unless identical content was stored before,
+// in which case the existing object is referenced instead
+func (u *Uploader) Upload(ctx context.Context, objectPath string, data []byte, contentType string) (*Object, error) {
+	hash := ContentHash(data)
+
+	if existingPath, ok := u.index.ObjectForHash(hash); ok {
+		exists, err := u.backend.Exists(ctx, existingPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check existing object: %w", err)
+		}
+		if exists {
+			log.Printf("Reusing stored object %s for identical content (sha256 %s)", existingPath, hash)
+			return &Object{
+				Path:         existingPath,
+				URL:          u.backend.URL(existingPath),
+				Hash:         hash,
+				Deduplicated: true,
+			}, nil
+		}
+		log.Printf("Indexed object %s is missing, uploading again", existingPath)
+	}
+
+	if err := u.backend.Put(ctx, objectPath, data, contentType); err != nil {
+		return nil, fmt.Errorf("failed to upload object: %w", err)
+	}
+
+	if err := u.index.RecordObject(hash, objectPath); err != nil {
+		return nil, fmt.Errorf("failed to record object hash: %w", err)
+	}
+
+	return &Object{
+		Path: objectPath,
+		URL:  u.backend.URL(objectPath),
+		Hash: hash,
+	}, nil
+}
+
+// ContentHash returns the hex-encoded SHA-256 of data
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxSignedURLExpiry is the longest lifetime SigV4 allows for a presigned URL
+	MaxSignedURLExpiry = 7 * 24 * time.Hour
+	// DefaultSignedURLExpiry is how long presigned URLs stay valid by default
+	DefaultSignedURLExpiry = 24 * time.Hour
+	// DefaultClockSkew is how far presigned URLs are backdated to tolerate a server clock behind ours
+	DefaultClockSkew = 5 * time.Minute
+
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "s3"
+)
+
+// S3Config configures an S3-compatible backend. Google Cloud Storage works
+// through its XML API with an HMAC key: use https://storage.googleapis.com as
+// the endpoint and "auto" as the region.
+type S3Config struct {
+	// Endpoint is the service URL; defaults to https://s3.<region>.amazonaws.com
+	Endpoint string
+	Region   string
+	Bucket   string
+
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// URLExpiry is how long presigned object URLs stay valid
+	URLExpiry time.Duration
+	// ClockSkew backdates presigned URLs so they are already valid on a
+	// server whose clock is behind ours; it is added to the expiry so the
+	// usable lifetime stays URLExpiry
+	ClockSkew time.Duration
+
+	HTTPClient *http.Client
+	// Now returns the signing time; defaults to time.Now
+	Now func() time.Time
+}
+
+// S3Backend stores objects in a private S3-compatible bucket and addresses
+// them with time-limited presigned URLs instead of public ones
+type S3Backend struct {
+	cfg      S3Config
+	endpoint *url.URL
+}
+
+// NewS3Backend creates a backend for cfg.Bucket using path-style requests
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("S3 access key ID and secret access key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	if cfg.URLExpiry <= 0 {
+		cfg.URLExpiry = DefaultSignedURLExpiry
+	}
+	if cfg.ClockSkew < 0 {
+		cfg.ClockSkew = 0
+	}
+	if cfg.URLExpiry+cfg.ClockSkew > MaxSignedURLExpiry {
+		return nil, fmt.Errorf("signed URL expiry plus clock skew must be at most %v", MaxSignedURLExpiry)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+
+	return &S3Backend{cfg: cfg, endpoint: endpoint}, nil
+}
+
+// Put uploads the object with a signed PUT request
+func (b *S3Backend) Put(ctx context.Context, objectPath string, data []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(objectPath).String(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	b.signRequest(req, data)
+
+	resp, err := b.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload object: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Exists checks for the object with a signed HEAD request
+func (b *S3Backend) Exists(ctx context.Context, objectPath string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, b.objectURL(objectPath).String(), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	b.signRequest(req, nil)
+
+	resp, err := b.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check object: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check object: HTTP %d", resp.StatusCode)
+	}
+}
+
+// URL returns a presigned GET URL for the object that expires after URLExpiry
+func (b *S3Backend) URL(objectPath string) string {
+	signedAt := b.cfg.Now().UTC().Add(-b.cfg.ClockSkew)
+	return b.presign(b.objectURL(objectPath), signedAt, b.cfg.URLExpiry+b.cfg.ClockSkew)
+}
+
+// presign returns u as a GET URL signed at signedAt that stays valid for expiry
+func (b *S3Backend) presign(u *url.URL, signedAt time.Time, expiry time.Duration) string {
+	amzDate := signedAt.Format("20060102T150405Z")
+	scope := b.scope(signedAt)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", b.cfg.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalQuery := canonicalQueryString(query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	signature := b.signature(signedAt, amzDate, scope, canonicalRequest)
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+// objectURL returns the path-style URL of an object
+func (b *S3Backend) objectURL(objectPath string) *url.URL {
+	u := *b.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.cfg.Bucket + "/" + cleanObjectPath(objectPath)
+	u.RawPath = strings.TrimSuffix(b.endpoint.EscapedPath(), "/") + "/" + uriEncode(b.cfg.Bucket, false) + "/" + uriEncode(cleanObjectPath(objectPath), true)
+	return &u
+}
+
+// signRequest adds SigV4 authorization headers to req for the given payload
+func (b *S3Backend) signRequest(req *http.Request, payload []byte) {
+	now := b.cfg.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQueryString(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := b.scope(now)
+	signature := b.signature(now, amzDate, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, b.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// scope returns the SigV4 credential scope for the signing day
+func (b *S3Backend) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + b.cfg.Region + "/" + sigV4Service + "/aws4_request"
+}
+
+// signature computes the SigV4 signature of canonicalRequest
+func (b *S3Backend) signature(t time.Time, amzDate, scope, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.cfg.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, b.cfg.Region)
+	key = hmacSHA256(key, sigV4Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalQueryString sorts and strictly URI-encodes query parameters as SigV4 requires
+func canonicalQueryString(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and slashes when keepSlash is set
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
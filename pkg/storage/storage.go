@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Backend stores image objects under slash-separated object paths
+type Backend interface {
+	// Put stores data at objectPath, overwriting any existing object
+	Put(ctx context.Context, objectPath string, data []byte, contentType string) error
+	// Exists reports whether an object is stored at objectPath
+	Exists(ctx context.Context, objectPath string) (bool, error)
+	// URL returns a URL for objectPath, or an empty string if the backend can't address objects
+	URL(objectPath string) string
+}
+
+// LocalBackend stores objects on the local filesystem
+type LocalBackend struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalBackend creates a backend rooted at dir. If baseURL is set, object
+// URLs are built by joining it with the object path.
+func NewLocalBackend(dir, baseURL string) (*LocalBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &LocalBackend{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// Put writes the object to disk
+func (b *LocalBackend) Put(ctx context.Context, objectPath string, data []byte, contentType string) error {
+	filePath := b.filePath(objectPath)
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	return nil
+}
+
+// Exists checks whether the object file is present
+func (b *LocalBackend) Exists(ctx context.Context, objectPath string) (bool, error) {
+	_, err := os.Stat(b.filePath(objectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return true, nil
+}
+
+// URL returns the public URL for the object if a base URL is configured
+func (b *LocalBackend) URL(objectPath string) string {
+	if b.baseURL == "" {
+		return ""
+	}
+
+	escaped := (&url.URL{Path: cleanObjectPath(objectPath)}).EscapedPath()
+	return b.baseURL + "/" + escaped
+}
+
+// filePath maps an object path to a location inside the storage directory
+func (b *LocalBackend) filePath(objectPath string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(cleanObjectPath(objectPath)))
+}
+
+// cleanObjectPath normalizes an object path and prevents escaping the storage root
+func cleanObjectPath(objectPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+objectPath), "/")
+}
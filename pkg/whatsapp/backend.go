@@ -0,0 +1,72 @@
+package whatsapp
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// whatsmeowClient is the subset of *whatsmeow.Client used by Client. Tests can
+// build a Client around a fake implementation instead of a live connection.
+type whatsmeowClient interface {
+	AddEventHandler(handler whatsmeow.EventHandler) uint32
+	Connect() error
+	Disconnect()
+	IsConnected() bool
+	SetProxyAddress(addr string, opts ...whatsmeow.SetProxyOptions) error
+	SetWSDialer(dialer *websocket.Dialer)
+
+	PairPhone(ctx context.Context, phone string, showPushNotification bool, clientType whatsmeow.PairClientType, clientDisplayName string) (string, error)
+	GetQRChannel(ctx context.Context) (<-chan whatsmeow.QRChannelItem, error)
+
+	GetProfilePictureInfo(jid types.JID, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error)
+	GetUserInfo(jids []types.JID) (map[types.JID]types.UserInfo, error)
+	IsOnWhatsApp(phones []string) ([]types.IsOnWhatsAppResponse, error)
+	GetNewsletterInfo(jid types.JID) (*types.NewsletterMetadata, error)
+	GetNewsletterInfoWithInvite(key string) (*types.NewsletterMetadata, error)
+
+	Download(ctx context.Context, msg whatsmeow.DownloadableMessage) ([]byte, error)
+
+	GetUserDevices(jids []types.JID) ([]types.JID, error)
+	RemoveCompanionDevice(ctx context.Context, jid types.JID) error
+	Logout(ctx context.Context) error
+
+	SendPresence(state types.Presence) error
+	SubscribePresence(jid types.JID) error
+
+	// DeviceStore returns the device store holding the session and contacts
+	DeviceStore() *store.Device
+}
+
+// whatsmeowAdapter exposes the Store field of *whatsmeow.Client as a method
+type whatsmeowAdapter struct {
+	*whatsmeow.Client
+}
+
+// DeviceStore returns the wrapped client's device store
+func (a whatsmeowAdapter) DeviceStore() *store.Device {
+	return a.Store
+}
+
+// RemoveCompanionDevice asks WhatsApp to unlink a device of this account.
+// whatsmeow only sends this request for its own device, from Logout.
+func (a whatsmeowAdapter) RemoveCompanionDevice(ctx context.Context, jid types.JID) error {
+	_, err := a.DangerousInternals().SendIQ(whatsmeow.DangerousInfoQuery{
+		Namespace: "md",
+		Type:      "set",
+		To:        types.ServerJID,
+		Context:   ctx,
+		Content: []waBinary.Node{{
+			Tag: "remove-companion-device",
+			Attrs: waBinary.Attrs{
+				"jid":    jid,
+				"reason": "user_initiated",
+			},
+		}},
+	})
+	return err
+}
@@ -0,0 +1,109 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+
+	"go-web-wa/pkg/batch"
+)
+
+const (
+	// DefaultFetchConcurrency is how many profile pictures GetProfilePictures fetches at once
+	DefaultFetchConcurrency = 4
+	// MaxFetchConcurrency caps the worker pool; more parallel lookups invite rate limits
+	MaxFetchConcurrency = 16
+	// DefaultDownloadConcurrency is how many image downloads a Client runs at once
+	DefaultDownloadConcurrency = 8
+)
+
+// PictureResult is the outcome of fetching one number in GetProfilePictures
+type PictureResult struct {
+	PhoneNumber string
+	Picture     *ProfilePicture
+	Err         error
+}
+
+// WithFetchConcurrency sets how many profile pictures GetProfilePictures fetches
+// in parallel, capped at MaxFetchConcurrency
+func WithFetchConcurrency(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.fetchConcurrency = min(n, MaxFetchConcurrency)
+		}
+	}
+}
+
+// WithDownloadConcurrency bounds how many image downloads run at once across
+// every caller of the Client: batches, single fetches and API requests alike.
+// Unlike WithFetchConcurrency, which limits the workers of one batch including
+// their picture info lookups, it only limits connections to the media CDN.
+func WithDownloadConcurrency(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.downloadSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// GetProfilePictures fetches the profile pictures of several phone numbers or
+// JIDs using a bounded worker pool. Results are returned in input order. When
+// ctx is cancelled no further fetches start and in-flight downloads are
+// aborted; the numbers not fetched carry the context error, which is also
+// returned.
+func (c *Client) GetProfilePictures(ctx context.Context, phoneNumbers []string) ([]PictureResult, error) {
+	results := make([]PictureResult, len(phoneNumbers))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(c.fetchConcurrency, len(phoneNumbers)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i] = PictureResult{PhoneNumber: phoneNumbers[i], Err: err}
+					continue
+				}
+				picture, err := c.GetProfilePictureWithInfo(ctx, phoneNumbers[i])
+				results[i] = PictureResult{PhoneNumber: phoneNumbers[i], Picture: picture, Err: err}
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(phoneNumbers); next++ {
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+
+	// Numbers that were never handed to a worker
+	for i := next; i < len(phoneNumbers); i++ {
+		results[i] = PictureResult{PhoneNumber: phoneNumbers[i], Err: ctx.Err()}
+	}
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// GetProfilePicturesOrdered fetches numbers concurrently like
+// GetProfilePictures and returns one batch.FetchItem per number in input
+// order, whatever order the fetches finish in. Items of numbers that weren't
+// fetched carry the error, including the context error after cancellation.
+func (c *Client) GetProfilePicturesOrdered(ctx context.Context, numbers []string) []batch.FetchItem {
+	results, _ := c.GetProfilePictures(ctx, numbers)
+
+	items := make([]batch.FetchItem, len(results))
+	for i, result := range results {
+		items[i] = batch.FetchItem{Number: result.PhoneNumber, Err: result.Err}
+		if result.Picture != nil {
+			items[i].Image = result.Picture.Data
+			items[i].Bytes = len(result.Picture.Data)
+		}
+	}
+	return items
+}
@@ -0,0 +1,63 @@
+package whatsapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestGetProfilePicturesOrderedKeepsInputOrder(t *testing.T) {
+	// Each picture's body is the number it belongs to
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/")))
+	}))
+	defer server.Close()
+
+	numbers := []string{"1000000001", "1000000002", "1000000003", "1000000004", "1000000005", "1000000006"}
+	latency := make(map[string]time.Duration)
+	for i, number := range numbers {
+		// Earlier numbers take longest, so they finish last
+		latency[number] = time.Duration(len(numbers)-i) * 15 * time.Millisecond
+	}
+
+	var mu sync.Mutex
+	var finished []string
+	fake := newFakeWhatsmeow()
+	fake.pictureInfo = func(jid types.JID, _ *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+		time.Sleep(latency[jid.User])
+		mu.Lock()
+		finished = append(finished, jid.User)
+		mu.Unlock()
+		return &types.ProfilePictureInfo{URL: server.URL + "/" + jid.User, ID: jid.User}, nil
+	}
+	c := newTestClient(t, fake, WithFetchConcurrency(len(numbers)))
+	c.setState(StateConnected)
+
+	targets := make([]string, len(numbers))
+	for i, number := range numbers {
+		targets[i] = number + "@" + types.DefaultUserServer
+	}
+	items := c.GetProfilePicturesOrdered(context.Background(), targets)
+
+	if finished[0] == numbers[0] {
+		t.Fatalf("fetches finished in order %v; the latencies didn't reorder them", finished)
+	}
+	if len(items) != len(targets) {
+		t.Fatalf("got %d items, want %d", len(items), len(targets))
+	}
+	for i, item := range items {
+		if item.Err != nil {
+			t.Errorf("item %d: %v", i, item.Err)
+		}
+		if item.Number != targets[i] || string(item.Image) != numbers[i] || item.Bytes != len(numbers[i]) {
+			t.Errorf("item %d = %s with image %q (%d bytes), want %s with image %q", i, item.Number, item.Image, item.Bytes, targets[i], numbers[i])
+		}
+	}
+}
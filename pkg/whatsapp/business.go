@@ -0,0 +1,42 @@
+package whatsapp
+
+import (
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// BusinessLevel tells how far a business account's name is vouched for
+type BusinessLevel string
+
+const (
+	// BusinessUnverified is a WhatsApp Business app account, which names itself
+	BusinessUnverified BusinessLevel = "unverified"
+	// BusinessOfficial is a Business Platform account whose name Meta has verified
+	BusinessOfficial BusinessLevel = "official"
+)
+
+// enterpriseIssuerPrefix starts the certificate issuer of Business Platform
+// accounts ("ent:wa"); Business app certificates are issued by "smb:wa"
+const enterpriseIssuerPrefix = "ent:"
+
+// Business is the verified name of a business account
+type Business struct {
+	Name  string        `json:"name"`
+	Level BusinessLevel `json:"level"`
+}
+
+// BusinessOf reads the business name and level from a verified name
+// certificate, returning nil for personal accounts. WhatsApp doesn't send the
+// green tick itself, so the level is derived from the certificate issuer.
+func BusinessOf(verifiedName *types.VerifiedName) *Business {
+	if verifiedName == nil || verifiedName.Details.GetVerifiedName() == "" {
+		return nil
+	}
+
+	business := &Business{Name: verifiedName.Details.GetVerifiedName(), Level: BusinessUnverified}
+	if strings.HasPrefix(verifiedName.Details.GetIssuer(), enterpriseIssuerPrefix) {
+		business.Level = BusinessOfficial
+	}
+	return business
+}
@@ -0,0 +1,99 @@
+package whatsapp
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// profileCache keeps downloaded profile pictures for a fixed time to live
+type profileCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[types.JID]cachedPicture
+}
+
+// cachedPicture is a downloaded profile picture and when it was fetched
+type cachedPicture struct {
+	picture   *ProfilePicture
+	fetchedAt time.Time
+}
+
+// newProfileCache creates a cache whose entries expire after ttl
+func newProfileCache(ttl time.Duration) *profileCache {
+	return &profileCache{
+		ttl:     ttl,
+		entries: make(map[types.JID]cachedPicture),
+	}
+}
+
+// get returns the cached picture for jid if it hasn't expired
+func (pc *profileCache) get(jid types.JID) (*ProfilePicture, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	entry, ok := pc.entries[jid.ToNonAD()]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(entry.fetchedAt) > pc.ttl {
+		delete(pc.entries, jid.ToNonAD())
+		return nil, false
+	}
+	return entry.picture, true
+}
+
+// put stores the picture for jid
+func (pc *profileCache) put(jid types.JID, picture *ProfilePicture) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.entries[jid.ToNonAD()] = cachedPicture{picture: picture, fetchedAt: time.Now()}
+}
+
+// remove drops the picture for jid
+func (pc *profileCache) remove(jid types.JID) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	delete(pc.entries, jid.ToNonAD())
+}
+
+// clear drops every cached picture
+func (pc *profileCache) clear() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	clear(pc.entries)
+}
+
+// WithProfileCache keeps downloaded profile pictures in memory for ttl so
+// repeated fetches of the same number don't hit WhatsApp again
+func WithProfileCache(ttl time.Duration) Option {
+	return func(c *Client) {
+		if ttl > 0 {
+			c.cache = newProfileCache(ttl)
+		}
+	}
+}
+
+// InvalidateProfileCache drops the cached profile picture of a phone number or
+// full JID so the next fetch goes to WhatsApp. Unparseable numbers are never
+// cached, so they are ignored.
+func (c *Client) InvalidateProfileCache(phoneNumber string) {
+	if c.cache == nil {
+		return
+	}
+
+	if jid, err := c.parsePhoneNumber(phoneNumber); err == nil {
+		c.cache.remove(jid)
+	}
+}
+
+// InvalidateAllProfileCache drops every cached profile picture
+func (c *Client) InvalidateAllProfileCache() {
+	if c.cache != nil {
+		c.cache.clear()
+	}
+}
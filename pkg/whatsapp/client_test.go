@@ -0,0 +1,248 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const testTarget = "1234567890@s.whatsapp.net"
+
+func TestGetProfilePictureNotConnected(t *testing.T) {
+	fake := newFakeWhatsmeow()
+	fake.pictureInfo = func(types.JID, *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+		t.Fatal("looked up picture info while disconnected")
+		return nil, nil
+	}
+	c := newTestClient(t, fake)
+
+	_, err := c.GetProfilePicture(context.Background(), testTarget)
+	if err == nil || !strings.Contains(err.Error(), "not connected") {
+		t.Fatalf("GetProfilePicture() error = %v, want not connected", err)
+	}
+}
+
+func TestGetProfilePictureNoPicture(t *testing.T) {
+	tests := []struct {
+		name string
+		info *types.ProfilePictureInfo
+		err  error
+	}{
+		{name: "not set", err: whatsmeow.ErrProfilePictureNotSet},
+		{name: "empty answer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeWhatsmeow()
+			fake.pictureInfo = func(types.JID, *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+				return tt.info, tt.err
+			}
+			c := newTestClient(t, fake)
+			c.setState(StateConnected)
+
+			_, err := c.GetProfilePicture(context.Background(), testTarget)
+			if !errors.Is(err, ErrNoProfilePicture) {
+				t.Fatalf("GetProfilePicture() error = %v, want ErrNoProfilePicture", err)
+			}
+		})
+	}
+}
+
+func TestGetProfilePictureDownloadFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	fake := newFakeWhatsmeow()
+	fake.pictureInfo = func(types.JID, *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+		return &types.ProfilePictureInfo{URL: server.URL + "/picture.jpg", ID: "1"}, nil
+	}
+	c := newTestClient(t, fake)
+	c.setState(StateConnected)
+
+	_, err := c.GetProfilePicture(context.Background(), testTarget)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Fatalf("GetProfilePicture() error = %v, want HTTP 400 download failure", err)
+	}
+}
+
+func TestGetProfilePictureRefreshRetriesAsNonContact(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expired.jpg" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	// The first URL has expired and the refresh is only answered after the
+	// non-contact introduction
+	answers := []struct {
+		info *types.ProfilePictureInfo
+		err  error
+	}{
+		{info: &types.ProfilePictureInfo{URL: server.URL + "/expired.jpg", ID: "1"}},
+		{err: whatsmeow.ErrProfilePictureUnauthorized},
+		{info: &types.ProfilePictureInfo{URL: server.URL + "/fresh.jpg", ID: "1"}},
+	}
+	var calls int
+	fake := newFakeWhatsmeow()
+	fake.pictureInfo = func(types.JID, *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+		answer := answers[min(calls, len(answers)-1)]
+		calls++
+		return answer.info, answer.err
+	}
+	fake.userInfo = func([]types.JID) (map[types.JID]types.UserInfo, error) {
+		return nil, nil
+	}
+	c := newTestClient(t, fake, WithNonContactRetry(true))
+	c.setState(StateConnected)
+
+	data, err := c.GetProfilePicture(context.Background(), testTarget)
+	if err != nil {
+		t.Fatalf("GetProfilePicture() error = %v", err)
+	}
+	if string(data) != "image" || calls != 3 {
+		t.Errorf("got %q after %d lookups, want the fresh image after 3", data, calls)
+	}
+}
+
+func TestDownloadImageSendsUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{name: "default", want: DefaultUserAgent},
+		{name: "DOWNLOAD_USER_AGENT", opts: []Option{WithUserAgent("go-web-wa-test/1.0")}, want: "go-web-wa-test/1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("User-Agent")
+				w.Write([]byte("image"))
+			}))
+			defer server.Close()
+
+			c := newTestClient(t, newFakeWhatsmeow(), tt.opts...)
+			if _, err := c.downloadImage(context.Background(), server.URL, false); err != nil {
+				t.Fatalf("downloadImage() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("User-Agent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteURIEscapesDatabasePath(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "odd?name#1%.db")
+
+	db, err := sql.Open("sqlite3", sqliteURI(dbPath)+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	var foreignKeys int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		t.Fatalf("PRAGMA foreign_keys: %v", err)
+	}
+	if foreignKeys != 1 {
+		t.Errorf("foreign_keys = %d, want 1; the parameters were cut off", foreignKeys)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("database not created at %s: %v", dbPath, err)
+	}
+}
+
+func TestConnectConcurrentCallsShareOneConnection(t *testing.T) {
+	fake := newFakeWhatsmeow()
+	// Come up a little later, as the real websocket handshake does
+	fake.onConnect = func(f *fakeWhatsmeow) error {
+		time.AfterFunc(20*time.Millisecond, f.setConnected)
+		return nil
+	}
+	c := newTestClient(t, fake, WithConnectPollInterval(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const callers = 16
+	errs := make(chan error, callers)
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Connect(ctx)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Connect() error = %v", err)
+		}
+	}
+	if got := fake.connects(); got != 1 {
+		t.Errorf("backend Connect called %d times, want 1", got)
+	}
+}
+
+func TestConnectHonoursContextDeadline(t *testing.T) {
+	fake := newFakeWhatsmeow()
+	// Never finish connecting
+	fake.onConnect = func(*fakeWhatsmeow) error { return nil }
+	c := newTestClient(t, fake, WithConnectPollInterval(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Connect(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Connect() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Connect() returned after %v, want close to the 50ms deadline", elapsed)
+	}
+}
+
+func TestConnectWithRetryBoundsEachAttempt(t *testing.T) {
+	fake := newFakeWhatsmeow()
+	// The first handshake hangs; the second comes up at once
+	fake.onConnect = func(f *fakeWhatsmeow) error {
+		if f.connects() > 1 {
+			f.setConnected()
+		}
+		return nil
+	}
+	c := newTestClient(t, fake, WithConnectPollInterval(time.Millisecond))
+	c.connectTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := c.ConnectWithRetry(ctx, 2); err != nil {
+		t.Fatalf("ConnectWithRetry() error = %v", err)
+	}
+	if got := fake.connects(); got != 2 {
+		t.Errorf("backend Connect called %d times, want 2", got)
+	}
+}
@@ -0,0 +1,56 @@
+package whatsapp
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// errNotModified is returned by downloadImage when the CDN answers a
+// conditional request with 304
+var errNotModified = errors.New("image not modified")
+
+// errURLExpired is returned by downloadImage when the CDN no longer serves
+// the URL, as happens once a signed media URL expires
+var errURLExpired = errors.New("media URL expired")
+
+// ValidatorStore remembers the ETag and Last-Modified of downloaded images by URL
+type ValidatorStore interface {
+	DownloadValidators(url string) (etag, lastModified string)
+	SetDownloadValidators(url, etag, lastModified string) error
+}
+
+// WithConditionalDownloads records the validators of every downloaded image in
+// store. When the caller accepts an unchanged answer (GetProfilePictureIfChanged),
+// downloads send If-None-Match/If-Modified-Since and a 304 is reported as
+// ErrPictureUnchanged instead of downloading the image again.
+func WithConditionalDownloads(store ValidatorStore) Option {
+	return func(c *Client) {
+		c.validators = store
+	}
+}
+
+// setConditionalHeaders adds the stored validators for url to req, if any
+func (c *Client) setConditionalHeaders(req *http.Request, url string) {
+	etag, lastModified := c.validators.DownloadValidators(url)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// recordValidators stores the validators the CDN sent with a downloaded image
+func (c *Client) recordValidators(url string, resp *http.Response) {
+	if c.validators == nil {
+		return
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+	if err := c.validators.SetDownloadValidators(url, etag, lastModified); err != nil {
+		log.Printf("Failed to store download validators: %v", err)
+	}
+}
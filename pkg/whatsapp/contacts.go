@@ -0,0 +1,134 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// GetProfilePictureByName fetches the profile picture of a saved contact,
+// matching its full name or push name case-insensitively
+func (c *Client) GetProfilePictureByName(ctx context.Context, name string) ([]byte, error) {
+	if err := c.requireConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := c.findContactByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	picture, err := c.getProfilePictureForJID(ctx, jid, name, "")
+	if err != nil {
+		return nil, err
+	}
+	return picture.Data, nil
+}
+
+// findContactByName resolves a contact name to a JID using the local contact store
+func (c *Client) findContactByName(ctx context.Context, name string) (types.JID, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return types.EmptyJID, fmt.Errorf("contact name is empty")
+	}
+
+	contacts, err := c.client.DeviceStore().Contacts.GetAllContacts(ctx)
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("failed to read contacts: %w", err)
+	}
+
+	var matches []types.JID
+	for jid, info := range contacts {
+		if strings.EqualFold(info.FullName, name) || strings.EqualFold(info.PushName, name) {
+			matches = append(matches, jid)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return types.EmptyJID, fmt.Errorf("%w named %q", ErrContactNotFound, name)
+	case 1:
+		return matches[0], nil
+	}
+
+	// List every match so the caller can pick a number instead
+	descriptions := make([]string, 0, len(matches))
+	for _, jid := range matches {
+		info := contacts[jid]
+		displayName := info.FullName
+		if displayName == "" {
+			displayName = info.PushName
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s (+%s)", displayName, jid.User))
+	}
+	sort.Strings(descriptions)
+
+	return types.EmptyJID, fmt.Errorf("%w: %q matches %s", ErrAmbiguousContact, name, strings.Join(descriptions, ", "))
+}
+
+// ContactName returns the display name of a phone number from the contact
+// store, or an empty string if none is known. Push names only resolve once app
+// state has synced, see WaitForAppStateSync.
+func (c *Client) ContactName(phoneNumber string) (string, error) {
+	jid, err := c.parsePhoneNumber(phoneNumber)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse phone number: %w", err)
+	}
+
+	info, err := c.client.DeviceStore().Contacts.GetContact(context.Background(), jid)
+	if err != nil {
+		return "", fmt.Errorf("failed to read contact: %w", err)
+	}
+
+	for _, name := range []string{info.FullName, info.PushName, info.BusinessName} {
+		if name != "" {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// DisplayName returns a label for a phone number that is never empty: the
+// contact store's name (saved, push or business name), then the verified
+// business name from WhatsApp, then the number itself in international
+// format. isNumber reports that no name was found. Lookup failures fall
+// through to the next tier and are logged.
+func (c *Client) DisplayName(phoneNumber string) (name string, isNumber bool) {
+	// Channels have a name of their own instead of contact entries
+	if IsNewsletter(phoneNumber) {
+		if name, err := c.newsletterName(phoneNumber); err != nil {
+			log.Printf("Failed to look up channel name for %s: %v", phoneNumber, err)
+		} else if name != "" {
+			return name, false
+		}
+		return strings.TrimSpace(phoneNumber), true
+	}
+
+	if name, err := c.ContactName(phoneNumber); err != nil {
+		log.Printf("Failed to look up contact name for %s: %v", phoneNumber, err)
+	} else if name != "" {
+		return name, false
+	}
+
+	if info, err := c.GetUserInfo(phoneNumber); err != nil {
+		log.Printf("Failed to look up verified name for %s: %v", phoneNumber, err)
+	} else if business := BusinessOf(info.VerifiedName); business != nil {
+		return business.Name, false
+	}
+
+	return c.formatNumber(phoneNumber), true
+}
+
+// formatNumber writes a phone number as +digits after normalization, and
+// other JIDs or unparsable input as given
+func (c *Client) formatNumber(phoneNumber string) string {
+	jid, err := c.parsePhoneNumber(phoneNumber)
+	if err != nil || jid.Server != types.DefaultUserServer {
+		return strings.TrimSpace(phoneNumber)
+	}
+	return "+" + jid.User
+}
@@ -0,0 +1,84 @@
+package whatsapp
+
+import (
+	"errors"
+	"testing"
+
+	"go.mau.fi/whatsmeow/proto/waVnameCert"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestDisplayNameFallback(t *testing.T) {
+	jid := types.NewJID("1234567890", types.DefaultUserServer)
+	verified := func(name string) *types.VerifiedName {
+		return &types.VerifiedName{Details: &waVnameCert.VerifiedNameCertificate_Details{VerifiedName: &name}}
+	}
+
+	tests := []struct {
+		name       string
+		contact    types.ContactInfo
+		contactErr error
+		userInfo   types.UserInfo
+		userErr    error
+		want       string
+		wantNumber bool
+	}{
+		{
+			name:     "saved name first",
+			contact:  types.ContactInfo{Found: true, FullName: "Saved", PushName: "Push"},
+			userInfo: types.UserInfo{VerifiedName: verified("Verified")},
+			want:     "Saved",
+		},
+		{
+			name:     "push name",
+			contact:  types.ContactInfo{Found: true, PushName: "Push"},
+			userInfo: types.UserInfo{VerifiedName: verified("Verified")},
+			want:     "Push",
+		},
+		{
+			name:     "verified name when the contact store is empty",
+			userInfo: types.UserInfo{VerifiedName: verified("Verified")},
+			want:     "Verified",
+		},
+		{
+			name:       "verified name when the contact lookup fails",
+			contactErr: errors.New("store closed"),
+			userInfo:   types.UserInfo{VerifiedName: verified("Verified")},
+			want:       "Verified",
+		},
+		{
+			name:       "number without any name",
+			want:       "+1234567890",
+			wantNumber: true,
+		},
+		{
+			name:       "number when every lookup fails",
+			contactErr: errors.New("store closed"),
+			userErr:    errors.New("timed out"),
+			want:       "+1234567890",
+			wantNumber: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeWhatsmeow()
+			fake.device.Contacts = &fakeContacts{
+				contacts: map[types.JID]types.ContactInfo{jid: tt.contact},
+				err:      tt.contactErr,
+			}
+			fake.userInfo = func([]types.JID) (map[types.JID]types.UserInfo, error) {
+				if tt.userErr != nil {
+					return nil, tt.userErr
+				}
+				return map[types.JID]types.UserInfo{jid: tt.userInfo}, nil
+			}
+			c := newTestClient(t, fake)
+			c.setState(StateConnected)
+
+			name, isNumber := c.DisplayName(jid.String())
+			if name != tt.want || isNumber != tt.wantNumber {
+				t.Errorf("DisplayName() = %q, %v, want %q, %v", name, isNumber, tt.want, tt.wantNumber)
+			}
+		})
+	}
+}
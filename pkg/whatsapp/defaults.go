@@ -0,0 +1,31 @@
+package whatsapp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// WithDefaultAvatarHashes treats images whose SHA-256 (hex) matches one of the
+// given hashes as ErrNoProfilePicture, so generic avatars don't count as a
+// picture. No hashes are built in: none of WhatsApp's generic avatars has been
+// confirmed against a downloaded image yet, so callers supply them.
+func WithDefaultAvatarHashes(hashes ...string) Option {
+	return func(c *Client) {
+		c.defaultAvatarHashes = make(map[string]bool)
+		for _, hash := range hashes {
+			if hash = strings.ToLower(strings.TrimSpace(hash)); hash != "" {
+				c.defaultAvatarHashes[hash] = true
+			}
+		}
+	}
+}
+
+// isDefaultAvatar reports whether imageData is one of the configured default avatars
+func (c *Client) isDefaultAvatar(imageData []byte) bool {
+	if len(c.defaultAvatarHashes) == 0 {
+		return false
+	}
+	sum := sha256.Sum256(imageData)
+	return c.defaultAvatarHashes[hex.EncodeToString(sum[:])]
+}
@@ -0,0 +1,80 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// LinkedDevice is one device of the paired account
+type LinkedDevice struct {
+	JID types.JID
+	// Primary is the phone that owns the account; it can't be unlinked
+	Primary bool
+	// Current is the device this client is logged in as
+	Current bool
+}
+
+// GetLinkedDevices lists every device of the paired account, the phone included
+func (c *Client) GetLinkedDevices() ([]LinkedDevice, error) {
+	ownID, err := c.ownID()
+	if err != nil {
+		return nil, err
+	}
+
+	jids, err := c.client.GetUserDevices([]types.JID{ownID.ToNonAD()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get linked devices: %w", err)
+	}
+
+	devices := make([]LinkedDevice, 0, len(jids))
+	for _, jid := range jids {
+		devices = append(devices, LinkedDevice{
+			JID:     jid,
+			Primary: jid.Device == 0,
+			Current: jid.Device == ownID.Device,
+		})
+	}
+	return devices, nil
+}
+
+// RemoveLinkedDevice unlinks a device of the paired account. Unlinking this
+// client logs it out and deletes the local session. WhatsApp may refuse to
+// unlink other devices from a linked device; that is reported as an error
+// and has to be done from the phone instead.
+func (c *Client) RemoveLinkedDevice(ctx context.Context, jid types.JID) error {
+	ownID, err := c.ownID()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case jid.User != ownID.User || jid.Server != ownID.Server:
+		return fmt.Errorf("%s is not a device of this account", jid)
+	case jid.Device == 0:
+		return fmt.Errorf("%s is the phone that owns the account and can't be unlinked", jid)
+	case jid.Device == ownID.Device:
+		if err := c.client.Logout(ctx); err != nil {
+			return fmt.Errorf("failed to log out: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.client.RemoveCompanionDevice(ctx, jid); err != nil {
+		return fmt.Errorf("WhatsApp refused to unlink %s (unlink it from the phone instead): %w", jid, err)
+	}
+	return nil
+}
+
+// ownID returns the JID of this device, failing unless paired and connected
+func (c *Client) ownID() (types.JID, error) {
+	id := c.client.DeviceStore().ID
+	if id == nil {
+		return types.EmptyJID, ErrNotLoggedIn
+	}
+	if err := c.requireConnected(); err != nil {
+		return types.EmptyJID, err
+	}
+	return *id, nil
+}
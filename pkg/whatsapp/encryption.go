@@ -0,0 +1,226 @@
+package whatsapp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedSuffix is appended to the database path for the encrypted copy
+const encryptedSuffix = ".enc"
+
+// sessionAAD binds ciphertexts to this file format
+var sessionAAD = []byte("go-web-wa session v1")
+
+// sessionFormatVersion leads every encrypted session, so the key derivation
+// can change later without breaking key rotation for older files
+const sessionFormatVersion = 1
+
+// sessionSaltSize is the length of the random scrypt salt stored after the version byte
+const sessionSaltSize = 16
+
+// scrypt cost parameters for deriving the AES-256 key from a passphrase
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// sessionCipher encrypts the session database file at rest with AES-256-GCM
+// under a key derived with scrypt. An encrypted file is the version byte, the
+// salt, the nonce and the ciphertext. The plaintext database only exists on
+// disk while a client is open.
+type sessionCipher struct {
+	// passphrases holds the current key first, followed by previous keys accepted for decryption
+	passphrases [][]byte
+}
+
+// newSessionCipher keeps the configured secrets; keys are derived per file
+// because each file has its own salt
+func newSessionCipher(key string, previousKeys ...string) *sessionCipher {
+	sc := &sessionCipher{}
+	for _, k := range append([]string{key}, previousKeys...) {
+		if k != "" {
+			sc.passphrases = append(sc.passphrases, []byte(k))
+		}
+	}
+	return sc
+}
+
+// unseal decrypts dbPath.enc to dbPath. A plaintext database left behind by an
+// unclean shutdown (or an existing unencrypted session being migrated) is used as-is.
+func (sc *sessionCipher) unseal(dbPath string) error {
+	if _, err := os.Stat(dbPath); err == nil {
+		log.Printf("Found unencrypted session database, it will be encrypted on close")
+		return nil
+	}
+
+	ciphertext, err := os.ReadFile(dbPath + encryptedSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		// Fresh session, nothing to decrypt yet
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read encrypted session: %w", err)
+	}
+
+	if len(ciphertext) < 1+sessionSaltSize {
+		return fmt.Errorf("encrypted session is truncated")
+	}
+	if version := ciphertext[0]; version != sessionFormatVersion {
+		return fmt.Errorf("unsupported session encryption version %d", version)
+	}
+	salt, sealed := ciphertext[1:1+sessionSaltSize], ciphertext[1+sessionSaltSize:]
+
+	for _, passphrase := range sc.passphrases {
+		key, err := deriveKey(passphrase, salt)
+		if err != nil {
+			return err
+		}
+		plaintext, err := decrypt(key, sealed)
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(dbPath, plaintext, 0600); err != nil {
+			return fmt.Errorf("failed to write decrypted session: %w", err)
+		}
+		return nil
+	}
+
+	return ErrSessionKeyMismatch
+}
+
+// seal encrypts dbPath with the current key to dbPath.enc and removes the plaintext files
+func (sc *sessionCipher) seal(dbPath string) error {
+	// Committed pages may still sit in the write-ahead log, which isn't sealed
+	if err := checkpoint(dbPath); err != nil {
+		return err
+	}
+
+	plaintext, err := os.ReadFile(dbPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session database: %w", err)
+	}
+
+	salt := make([]byte, sessionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := deriveKey(sc.passphrases[0], salt)
+	if err != nil {
+		return err
+	}
+	sealed, err := encrypt(key, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt session: %w", err)
+	}
+	ciphertext := append(append([]byte{sessionFormatVersion}, salt...), sealed...)
+
+	tmpPath := dbPath + encryptedSuffix + ".tmp"
+	if err := os.WriteFile(tmpPath, ciphertext, 0600); err != nil {
+		return fmt.Errorf("failed to write encrypted session: %w", err)
+	}
+	if err := os.Rename(tmpPath, dbPath+encryptedSuffix); err != nil {
+		return fmt.Errorf("failed to replace encrypted session: %w", err)
+	}
+
+	// Remove the plaintext database and any SQLite side files
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove plaintext session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkpoint moves a leftover write-ahead log of dbPath into the database
+// file, and refuses to go on while the log still holds pages
+func checkpoint(dbPath string) error {
+	if walPending(dbPath) == nil {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite3", sqliteURI(dbPath))
+	if err != nil {
+		return fmt.Errorf("failed to open session database: %w", err)
+	}
+	_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint session database: %w", err)
+	}
+	return walPending(dbPath)
+}
+
+// walPending returns an error when dbPath has a non-empty write-ahead log
+func walPending(dbPath string) error {
+	info, err := os.Stat(dbPath + "-wal")
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check session write-ahead log: %w", err)
+	}
+	return fmt.Errorf("session write-ahead log still holds %d bytes, not sealing", info.Size())
+}
+
+// deriveKey derives the AES-256 key for passphrase and salt with scrypt
+func deriveKey(passphrase, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive session key: %w", err)
+	}
+	return key, nil
+}
+
+// encrypt seals plaintext as nonce || ciphertext
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, sessionAAD), nil
+}
+
+// decrypt opens data produced by encrypt
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted session is truncated")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, sessionAAD)
+}
+
+// newGCM creates an AES-GCM AEAD for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,171 @@
+package whatsapp
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionCipherRoundTrip(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "whatsapp.db")
+	want := []byte("session contents")
+	if err := os.WriteFile(dbPath, want, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sc := newSessionCipher("secret")
+	if err := sc.seal(dbPath); err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	if _, err := os.Stat(dbPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("plaintext left behind after seal (stat error %v)", err)
+	}
+	sealed, err := os.ReadFile(dbPath + encryptedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed[0] != sessionFormatVersion {
+		t.Errorf("version byte = %d, want %d", sealed[0], sessionFormatVersion)
+	}
+	if bytes.Contains(sealed, want) {
+		t.Error("encrypted session contains the plaintext")
+	}
+
+	if err := sc.unseal(dbPath); err != nil {
+		t.Fatalf("unseal() error = %v", err)
+	}
+	if got, _ := os.ReadFile(dbPath); !bytes.Equal(got, want) {
+		t.Errorf("unsealed contents = %q, want %q", got, want)
+	}
+}
+
+func TestSessionCipherSaltsEverySeal(t *testing.T) {
+	dir := t.TempDir()
+	var salts [][]byte
+	for _, name := range []string{"a.db", "b.db"} {
+		dbPath := filepath.Join(dir, name)
+		if err := os.WriteFile(dbPath, []byte("same contents"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := newSessionCipher("secret").seal(dbPath); err != nil {
+			t.Fatal(err)
+		}
+		sealed, err := os.ReadFile(dbPath + encryptedSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		salts = append(salts, sealed[1:1+sessionSaltSize])
+	}
+	if bytes.Equal(salts[0], salts[1]) {
+		t.Error("two seals under the same key share a salt")
+	}
+}
+
+func TestSessionCipherKeyRotation(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "whatsapp.db")
+	if err := os.WriteFile(dbPath, []byte("session contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := newSessionCipher("old").seal(dbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newSessionCipher("new").unseal(dbPath); !errors.Is(err, ErrSessionKeyMismatch) {
+		t.Fatalf("unseal() with the wrong key error = %v, want ErrSessionKeyMismatch", err)
+	}
+
+	rotated := newSessionCipher("new", "old")
+	if err := rotated.unseal(dbPath); err != nil {
+		t.Fatalf("unseal() with the previous key error = %v", err)
+	}
+	if err := rotated.seal(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := newSessionCipher("new").unseal(dbPath); err != nil {
+		t.Errorf("unseal() after rotation error = %v, want the new key alone to work", err)
+	}
+}
+
+func TestSessionCipherRejectsUnknownVersion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "whatsapp.db")
+	sealed := append([]byte{sessionFormatVersion + 1}, make([]byte, 64)...)
+	if err := os.WriteFile(dbPath+encryptedSuffix, sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := newSessionCipher("secret").unseal(dbPath)
+	if err == nil || errors.Is(err, ErrSessionKeyMismatch) {
+		t.Fatalf("unseal() error = %v, want an unsupported version error", err)
+	}
+}
+
+func TestSessionCipherCheckpointsWriteAheadLog(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "live.db")
+	db, err := sql.Open("sqlite3", sqliteURI(livePath)+"?_journal_mode=WAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (v TEXT); INSERT INTO t VALUES ('kept')"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copy the files while the connection is open, as a crash would leave
+	// them, so the committed row is only in the -wal file
+	dbPath := filepath.Join(dir, "whatsapp.db")
+	for _, suffix := range []string{"", "-wal"} {
+		data, err := os.ReadFile(livePath + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dbPath+suffix, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sc := newSessionCipher("secret")
+	if err := sc.seal(dbPath); err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	if err := sc.unseal(dbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	unsealed, err := sql.Open("sqlite3", sqliteURI(dbPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsealed.Close()
+	var v string
+	if err := unsealed.QueryRow("SELECT v FROM t").Scan(&v); err != nil || v != "kept" {
+		t.Errorf("row after seal and unseal = %q, %v; want the committed row", v, err)
+	}
+}
+
+func TestNewClientResealsWhenOpeningFails(t *testing.T) {
+	sessionPath := t.TempDir()
+	dbPath := filepath.Join(sessionPath, "whatsapp.db")
+	sealed := newSessionCipher("secret")
+
+	// A sealed database that isn't valid SQLite, so opening it fails after decryption
+	if err := os.WriteFile(dbPath, []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := sealed.seal(dbPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewClient(sessionPath, WithSessionEncryption("secret")); err == nil {
+		t.Fatal("NewClient() succeeded on a corrupt database")
+	}
+	if _, err := os.Stat(dbPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("plaintext database left behind after NewClient failed (stat error %v)", err)
+	}
+	if _, err := os.Stat(dbPath + encryptedSuffix); err != nil {
+		t.Errorf("encrypted database missing: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package whatsapp
+
+import "errors"
+
+var (
+	// ErrProfileInfoTimeout is returned when the profile picture info lookup exceeds its deadline
+	ErrProfileInfoTimeout = errors.New("profile picture info lookup timed out")
+
+	// ErrPrivacyRestricted is returned when the target hides their profile picture from us
+	ErrPrivacyRestricted = errors.New("profile picture hidden by privacy settings")
+
+	// ErrNotAuthorized is returned when WhatsApp refuses to show the profile picture,
+	// even after the non-contact retry if enabled. It also matches ErrPrivacyRestricted.
+	ErrNotAuthorized = errors.New("not authorized to view profile picture")
+
+	// ErrNoProfilePicture is returned when the target genuinely has no profile picture
+	ErrNoProfilePicture = errors.New("no profile picture found")
+
+	// ErrPictureUnchanged is returned by GetProfilePictureIfChanged when WhatsApp
+	// reports the picture still has the known ID
+	ErrPictureUnchanged = errors.New("profile picture unchanged")
+
+	// ErrContactNotFound is returned when no saved contact matches a name
+	ErrContactNotFound = errors.New("no contact found")
+
+	// ErrAmbiguousContact is returned when several saved contacts match a name
+	ErrAmbiguousContact = errors.New("contact name is ambiguous")
+
+	// ErrSessionKeyMismatch is returned when the encrypted session can't be decrypted with the configured keys
+	ErrSessionKeyMismatch = errors.New("session encryption key does not match the encrypted session")
+
+	// ErrInvalidDeviceStore is returned when the session database holds a device that can't be used
+	ErrInvalidDeviceStore = errors.New("session device store is unusable; delete the session and pair again")
+
+	// ErrNotLoggedIn is returned by account operations when the session isn't paired
+	ErrNotLoggedIn = errors.New("not logged in to WhatsApp; run the pair command first")
+
+	// ErrNoMedia is returned by DownloadMedia for messages without a downloadable attachment
+	ErrNoMedia = errors.New("message has no downloadable media")
+)
@@ -0,0 +1,123 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// fakeWhatsmeow stands in for *whatsmeow.Client. Methods a test doesn't
+// stub fall through to the nil embedded interface and panic.
+type fakeWhatsmeow struct {
+	whatsmeowClient
+
+	// pictureInfo answers GetProfilePictureInfo
+	pictureInfo func(jid types.JID, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error)
+	// userInfo answers GetUserInfo
+	userInfo func(jids []types.JID) (map[types.JID]types.UserInfo, error)
+	// onConnect runs on every Connect call; the fake is connected once it
+	// calls setConnected
+	onConnect func(f *fakeWhatsmeow) error
+
+	device *store.Device
+
+	mu           sync.Mutex
+	connected    bool
+	connectCalls int
+}
+
+// newFakeWhatsmeow returns a fake whose device is paired
+func newFakeWhatsmeow() *fakeWhatsmeow {
+	return &fakeWhatsmeow{
+		device: &store.Device{ID: &types.JID{User: "1000000000", Server: types.DefaultUserServer}},
+	}
+}
+
+// newTestClient builds a Client around fake without opening a session store
+func newTestClient(t *testing.T, fake *fakeWhatsmeow, opts ...Option) *Client {
+	t.Helper()
+
+	c, err := newClient(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	c.client = fake
+	return c
+}
+
+func (f *fakeWhatsmeow) Connect() error {
+	f.mu.Lock()
+	f.connectCalls++
+	f.mu.Unlock()
+
+	if f.onConnect != nil {
+		return f.onConnect(f)
+	}
+	f.setConnected()
+	return nil
+}
+
+func (f *fakeWhatsmeow) setConnected() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connected = true
+}
+
+func (f *fakeWhatsmeow) IsConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.connected
+}
+
+func (f *fakeWhatsmeow) Disconnect() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connected = false
+}
+
+func (f *fakeWhatsmeow) connects() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.connectCalls
+}
+
+func (f *fakeWhatsmeow) DeviceStore() *store.Device {
+	return f.device
+}
+
+func (f *fakeWhatsmeow) GetProfilePictureInfo(jid types.JID, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+	return f.pictureInfo(jid, params)
+}
+
+func (f *fakeWhatsmeow) GetUserInfo(jids []types.JID) (map[types.JID]types.UserInfo, error) {
+	return f.userInfo(jids)
+}
+
+func (f *fakeWhatsmeow) SubscribePresence(types.JID) error {
+	return nil
+}
+
+// fakeContacts is a contact store holding contacts, failing every lookup with
+// err when set. Methods other than GetContact panic.
+type fakeContacts struct {
+	store.ContactStore
+
+	contacts map[types.JID]types.ContactInfo
+	err      error
+}
+
+func (f *fakeContacts) GetContact(_ context.Context, user types.JID) (types.ContactInfo, error) {
+	return f.contacts[user], f.err
+}
+
+func (f *fakeContacts) GetAllContacts(context.Context) (map[types.JID]types.ContactInfo, error) {
+	return f.contacts, f.err
+}
@@ -0,0 +1,39 @@
+package whatsapp
+
+import (
+	"fmt"
+	"log/slog"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// slogLogger passes whatsmeow's errors to the default slog logger, so they
+// share the configured format and phone number masking. Like the stdout
+// logger it replaces, it drops everything below error level.
+type slogLogger struct {
+	module string
+}
+
+// newLogger returns a whatsmeow logger for module
+func newLogger(module string) waLog.Logger {
+	return slogLogger{module: module}
+}
+
+// Errorf logs an error from whatsmeow
+func (l slogLogger) Errorf(msg string, args ...interface{}) {
+	slog.Error(fmt.Sprintf(msg, args...), "module", l.module)
+}
+
+// Warnf drops whatsmeow warnings
+func (l slogLogger) Warnf(string, ...interface{}) {}
+
+// Infof drops whatsmeow info messages
+func (l slogLogger) Infof(string, ...interface{}) {}
+
+// Debugf drops whatsmeow debug messages
+func (l slogLogger) Debugf(string, ...interface{}) {}
+
+// Sub returns a logger for a submodule
+func (l slogLogger) Sub(module string) waLog.Logger {
+	return slogLogger{module: l.module + "/" + module}
+}
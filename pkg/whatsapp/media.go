@@ -0,0 +1,46 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// mediaMessage is an attachment that can be downloaded and knows its MIME type
+type mediaMessage interface {
+	whatsmeow.DownloadableMessage
+	GetMimetype() string
+}
+
+// DownloadMedia downloads and decrypts the attachment of a received message,
+// returning its bytes and MIME type. Images, videos, audio, documents and
+// stickers are supported; other messages return ErrNoMedia.
+func (c *Client) DownloadMedia(ctx context.Context, msg *events.Message) ([]byte, string, error) {
+	if msg == nil || msg.Message == nil {
+		return nil, "", ErrNoMedia
+	}
+
+	var media mediaMessage
+	switch m := msg.Message; {
+	case m.GetImageMessage() != nil:
+		media = m.GetImageMessage()
+	case m.GetVideoMessage() != nil:
+		media = m.GetVideoMessage()
+	case m.GetAudioMessage() != nil:
+		media = m.GetAudioMessage()
+	case m.GetDocumentMessage() != nil:
+		media = m.GetDocumentMessage()
+	case m.GetStickerMessage() != nil:
+		media = m.GetStickerMessage()
+	default:
+		return nil, "", ErrNoMedia
+	}
+
+	data, err := c.client.Download(ctx, media)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download media of message %s: %w", msg.Info.ID, err)
+	}
+	return data, media.GetMimetype(), nil
+}
@@ -0,0 +1,121 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// newsletterMediaHost serves channel pictures, which WhatsApp often sends
+// as a direct path without a full URL
+const newsletterMediaHost = "https://mmg.whatsapp.net"
+
+// IsNewsletter reports whether target names a WhatsApp channel, either as a
+// channel link (https://whatsapp.com/channel/<invite>) or as a JID ending in @newsletter
+func IsNewsletter(target string) bool {
+	target = strings.TrimSpace(target)
+	return strings.HasPrefix(target, whatsmeow.NewsletterLinkPrefix) ||
+		strings.HasSuffix(target, "@"+types.NewsletterServer)
+}
+
+// GetNewsletterInfo fetches the metadata of a channel given its link, invite
+// code or @newsletter JID
+func (c *Client) GetNewsletterInfo(inviteOrJID string) (*types.NewsletterMetadata, error) {
+	if err := c.requireConnected(); err != nil {
+		return nil, err
+	}
+
+	target := strings.TrimSpace(inviteOrJID)
+	var info *types.NewsletterMetadata
+	var err error
+	if strings.Contains(target, "@") {
+		jid, parseErr := types.ParseJID(target)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid JID %q: %w", target, parseErr)
+		}
+		if jid.Server != types.NewsletterServer {
+			return nil, fmt.Errorf("%s is not a channel JID", target)
+		}
+		info, err = c.client.GetNewsletterInfo(jid)
+	} else {
+		info, err = c.client.GetNewsletterInfoWithInvite(target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel info for %s: %w", target, err)
+	}
+
+	c.mu.Lock()
+	if c.newsletterNames == nil {
+		c.newsletterNames = make(map[string]string)
+	}
+	c.newsletterNames[target] = info.ThreadMeta.Name.Text
+	c.mu.Unlock()
+
+	return info, nil
+}
+
+// GetNewsletterPicture fetches and downloads the picture of a channel given
+// its link, invite code or @newsletter JID
+func (c *Client) GetNewsletterPicture(ctx context.Context, inviteOrJID string) (*ProfilePicture, error) {
+	return c.getNewsletterPicture(ctx, inviteOrJID, "")
+}
+
+// getNewsletterPicture downloads a channel's picture unless it still has
+// existingID, in which case it returns ErrPictureUnchanged
+func (c *Client) getNewsletterPicture(ctx context.Context, inviteOrJID, existingID string) (*ProfilePicture, error) {
+	info, err := c.GetNewsletterInfo(inviteOrJID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The preview is a thumbnail, only used when there is no full picture
+	pic := info.ThreadMeta.Picture
+	if pic == nil || (pic.URL == "" && pic.DirectPath == "") {
+		pic = &info.ThreadMeta.Preview
+	}
+	if pic.URL == "" && pic.DirectPath == "" {
+		return nil, fmt.Errorf("%w for %s", ErrNoProfilePicture, inviteOrJID)
+	}
+	if existingID != "" && pic.ID == existingID {
+		return nil, fmt.Errorf("%w for %s", ErrPictureUnchanged, inviteOrJID)
+	}
+
+	url := pic.URL
+	if url == "" {
+		url = newsletterMediaHost + pic.DirectPath
+	}
+	imageData, err := c.downloadImage(ctx, url, existingID != "")
+	if errors.Is(err, errNotModified) {
+		return nil, fmt.Errorf("%w for %s (not modified on the CDN)", ErrPictureUnchanged, inviteOrJID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download channel picture: %w", err)
+	}
+
+	return &ProfilePicture{
+		Data: imageData,
+		ID:   pic.ID,
+		Type: pic.Type,
+	}, nil
+}
+
+// newsletterName returns the channel name, from the last lookup if there was one
+func (c *Client) newsletterName(inviteOrJID string) (string, error) {
+	target := strings.TrimSpace(inviteOrJID)
+	c.mu.Lock()
+	name, ok := c.newsletterNames[target]
+	c.mu.Unlock()
+	if ok {
+		return name, nil
+	}
+
+	info, err := c.GetNewsletterInfo(target)
+	if err != nil {
+		return "", err
+	}
+	return info.ThreadMeta.Name.Text, nil
+}
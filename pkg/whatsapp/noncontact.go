@@ -0,0 +1,41 @@
+package whatsapp
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// nonContactSettleDelay gives WhatsApp a moment to process the user lookup
+// and presence subscription before the picture is requested again
+const nonContactSettleDelay = 2 * time.Second
+
+// WithNonContactRetry enables a best-effort retry when WhatsApp refuses a
+// profile picture: the client looks the user up and subscribes to their
+// presence, which exchanges the privacy tokens some accounts require, then asks
+// again once. It cannot get around a contact who hides their picture on purpose.
+func WithNonContactRetry(enabled bool) Option {
+	return func(c *Client) {
+		c.nonContactRetry = enabled
+	}
+}
+
+// retryAsNonContact introduces us to jid and repeats the profile picture lookup
+func (c *Client) retryAsNonContact(ctx context.Context, jid types.JID, label string, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+	log.Printf("Profile picture for %s not authorized, retrying after user lookup and presence subscription", label)
+
+	if _, err := c.client.GetUserInfo([]types.JID{jid}); err != nil {
+		log.Printf("User lookup for %s failed: %v", label, err)
+	}
+	if err := c.client.SubscribePresence(jid); err != nil {
+		log.Printf("Presence subscription for %s failed: %v", label, err)
+	}
+
+	if err := sleepContext(ctx, nonContactSettleDelay); err != nil {
+		return nil, err
+	}
+	return c.getProfilePictureInfo(ctx, jid, params)
+}
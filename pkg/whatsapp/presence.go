@@ -0,0 +1,130 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// SubscribePresence asks WhatsApp for presence updates of the given numbers.
+// Subscriptions are renewed automatically whenever the client reconnects.
+func (c *Client) SubscribePresence(phoneNumbers ...string) error {
+	targets := make(map[types.JID]string, len(phoneNumbers))
+	for _, phoneNumber := range phoneNumbers {
+		jid, err := c.parsePhoneNumber(phoneNumber)
+		if err != nil {
+			return fmt.Errorf("failed to parse phone number: %w", err)
+		}
+		targets[jid.ToNonAD()] = phoneNumber
+	}
+
+	c.mu.Lock()
+	for jid, phoneNumber := range targets {
+		c.presenceTargets[jid] = phoneNumber
+	}
+	c.mu.Unlock()
+
+	return c.subscribePresence(targets)
+}
+
+// OnOnline registers a callback invoked when a subscribed number comes online.
+// Callbacks run synchronously on the event goroutine, so they should return quickly.
+func (c *Client) OnOnline(fn func(phoneNumber string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.onlineHandlers = append(c.onlineHandlers, fn)
+}
+
+// subscribePresence sends the subscription requests for targets
+func (c *Client) subscribePresence(targets map[types.JID]string) error {
+	// WhatsApp only delivers presence updates to clients that are available themselves
+	if err := c.sendAvailable(); err != nil {
+		return err
+	}
+
+	for jid, phoneNumber := range targets {
+		if err := c.client.SubscribePresence(jid); err != nil {
+			return fmt.Errorf("failed to subscribe to presence of %s: %w", phoneNumber, err)
+		}
+	}
+
+	return nil
+}
+
+// resubscribePresence renews every presence subscription after a reconnect
+func (c *Client) resubscribePresence() {
+	c.mu.Lock()
+	targets := make(map[types.JID]string, len(c.presenceTargets))
+	for jid, phoneNumber := range c.presenceTargets {
+		targets[jid] = phoneNumber
+	}
+	c.mu.Unlock()
+
+	if len(targets) == 0 {
+		return
+	}
+
+	if err := c.subscribePresence(targets); err != nil {
+		log.Printf("Failed to renew presence subscriptions: %v", err)
+	}
+}
+
+// handlePresence notifies the online callbacks when a subscribed number goes from offline to online
+func (c *Client) handlePresence(evt *events.Presence) {
+	jid := evt.From.ToNonAD()
+
+	c.mu.Lock()
+	phoneNumber, subscribed := c.presenceTargets[jid]
+	wasOnline := c.online[jid]
+	c.online[jid] = !evt.Unavailable
+	handlers := append([]func(string){}, c.onlineHandlers...)
+	c.mu.Unlock()
+
+	if !subscribed || evt.Unavailable || wasOnline {
+		return
+	}
+
+	log.Printf("%s came online", phoneNumber)
+	for _, handler := range handlers {
+		handler(phoneNumber)
+	}
+}
+
+// KeepAlive sends available presence every interval until ctx is done, so
+// WhatsApp doesn't unlink the device for being idle. Note that this shows the
+// account as online to its contacts.
+func (c *Client) KeepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.IsConnected() {
+				continue
+			}
+			if err := c.sendAvailable(); err != nil {
+				log.Printf("Keepalive presence failed: %v", err)
+			}
+		}
+	}
+}
+
+// sendAvailable marks the account as available and records when it was sent
+func (c *Client) sendAvailable() error {
+	if err := c.client.SendPresence(types.PresenceAvailable); err != nil {
+		return fmt.Errorf("failed to send presence: %w", err)
+	}
+
+	c.mu.Lock()
+	c.lastPresenceSent = time.Now()
+	c.mu.Unlock()
+	return nil
+}
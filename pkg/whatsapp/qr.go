@@ -0,0 +1,127 @@
+package whatsapp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mdp/qrterminal/v3"
+	"rsc.io/qr"
+)
+
+// QRStyle selects how PairQR shows pairing codes
+type QRStyle string
+
+const (
+	// QRHalfBlock prints compact half-height blocks on the terminal
+	QRHalfBlock QRStyle = "half-block"
+	// QRFullBlock prints full-height coloured blocks, which more terminals render correctly
+	QRFullBlock QRStyle = "full-block"
+	// QRPNG writes each code to a PNG file instead of printing it
+	QRPNG QRStyle = "png"
+)
+
+// Default QR options, matching what PairQR always printed
+const (
+	DefaultQRStyle   = QRHalfBlock
+	DefaultQRLevel   = "L"
+	DefaultQRPNGPath = "pairing-qr.png"
+)
+
+// QROptions controls how PairQR shows pairing codes. Zero fields keep the default.
+type QROptions struct {
+	Style QRStyle
+	// Level is the error correction level: L, M, Q or H. Higher levels make
+	// the code denser but more tolerant of rendering glitches.
+	Level string
+	// PNGPath is the file the png style overwrites with every new code
+	PNGPath string
+}
+
+// WithQROptions sets how PairQR shows pairing codes
+func WithQROptions(opts QROptions) Option {
+	return func(c *Client) {
+		if opts.Style != "" {
+			c.qrOptions.Style = opts.Style
+		}
+		if opts.Level != "" {
+			c.qrOptions.Level = opts.Level
+		}
+		if opts.PNGPath != "" {
+			c.qrOptions.PNGPath = opts.PNGPath
+		}
+	}
+}
+
+// WithQRHandler calls fn with every pairing QR code PairQR receives, in
+// addition to printing it on the terminal. WhatsApp rotates the code every
+// few seconds, so fn is called once per rotation.
+func WithQRHandler(fn func(code string)) Option {
+	return func(c *Client) {
+		c.qrHandler = fn
+	}
+}
+
+// QRCodePNG renders a pairing QR code as a PNG image
+func QRCodePNG(code string) ([]byte, error) {
+	encoded, err := qr.Encode(code, qr.L)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return encoded.PNG(), nil
+}
+
+// qrLevel parses an error correction level name
+func qrLevel(level string) (qr.Level, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	default:
+		return 0, fmt.Errorf("invalid QR error correction level %q: use L, M, Q or H", level)
+	}
+}
+
+// validate reports options PairQR can't render with
+func (o QROptions) validate() error {
+	if _, err := qrLevel(o.Level); err != nil {
+		return err
+	}
+	switch o.Style {
+	case QRHalfBlock, QRFullBlock, QRPNG:
+		return nil
+	default:
+		return fmt.Errorf("invalid QR style %q: use %s, %s or %s", o.Style, QRHalfBlock, QRFullBlock, QRPNG)
+	}
+}
+
+// showQR renders code in the configured style to w or the PNG file
+func (o QROptions) showQR(code string, w io.Writer) error {
+	level, err := qrLevel(o.Level)
+	if err != nil {
+		return err
+	}
+
+	switch o.Style {
+	case QRFullBlock:
+		qrterminal.Generate(code, level, w)
+	case QRPNG:
+		encoded, err := qr.Encode(code, level)
+		if err != nil {
+			return fmt.Errorf("failed to encode QR code: %w", err)
+		}
+		if err := os.WriteFile(o.PNGPath, encoded.PNG(), 0o600); err != nil {
+			return fmt.Errorf("failed to write QR code: %w", err)
+		}
+		fmt.Fprintf(w, "QR code written to %s\n", o.PNGPath)
+	default:
+		qrterminal.GenerateHalfBlock(code, level, w)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ReadinessCheck is a condition WaitUntilReady waits for once connected
+type ReadinessCheck string
+
+const (
+	// ReadyAppState waits until contact names are available, see WaitForAppStateSync
+	ReadyAppState ReadinessCheck = "app_state"
+	// ReadyOfflineSync waits until WhatsApp has delivered the events queued
+	// while the session was offline, such as picture changes
+	ReadyOfflineSync ReadinessCheck = "offline_sync"
+)
+
+// DefaultReadinessChecks are used unless WithReadinessChecks overrides them
+var DefaultReadinessChecks = []ReadinessCheck{ReadyAppState}
+
+// WithReadinessChecks sets what WaitUntilReady waits for besides the
+// connection. No checks means being connected is enough.
+func WithReadinessChecks(checks ...ReadinessCheck) Option {
+	return func(c *Client) {
+		c.readinessChecks = checks
+	}
+}
+
+// WaitUntilReady blocks until the client is ready to fetch: connected and
+// authenticated (StateConnected), then every configured readiness check
+// passed, in order. The error names the first condition not met before ctx
+// is done. An app state sync that doesn't finish by ctx's deadline is only
+// logged, since DisplayName falls back to verified names and numbers.
+func (c *Client) WaitUntilReady(ctx context.Context) error {
+	if err := c.WaitForState(ctx, StateConnected); err != nil {
+		return err
+	}
+
+	for _, check := range c.readinessChecks {
+		var err error
+		switch check {
+		case ReadyAppState:
+			err = c.WaitForAppStateSync(ctx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("Continuing without contact names: %v", err)
+				err = nil
+			}
+		case ReadyOfflineSync:
+			err = c.waitForOfflineSync(ctx)
+		default:
+			err = fmt.Errorf("unknown readiness check %q", check)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForOfflineSync blocks until the events missed while offline have been delivered
+func (c *Client) waitForOfflineSync(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		synced := c.offlineSynced
+		changed := c.stateChanged
+		c.mu.Unlock()
+
+		if synced {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for offline sync: %w", ctx.Err())
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitUntilReadyAppStateTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		checks  []ReadinessCheck
+		wantErr bool
+	}{
+		{name: "app_state only warns", checks: []ReadinessCheck{ReadyAppState}},
+		{name: "offline_sync still fails", checks: []ReadinessCheck{ReadyAppState, ReadyOfflineSync}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No contacts stored and no app state patch ever arrives
+			fake := newFakeWhatsmeow()
+			fake.device.Contacts = &fakeContacts{}
+			c := newTestClient(t, fake, WithReadinessChecks(tt.checks...))
+			c.setState(StateConnected)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			err := c.WaitUntilReady(ctx)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("WaitUntilReady() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("WaitUntilReady() error = %v, want context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
+
+func TestWaitUntilReadyStopsOnCancel(t *testing.T) {
+	fake := newFakeWhatsmeow()
+	fake.device.Contacts = &fakeContacts{}
+	c := newTestClient(t, fake)
+	c.setState(StateConnected)
+
+	// A cancelled run, such as a shutdown, isn't a slow sync to ride out
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := c.WaitUntilReady(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitUntilReady() error = %v, want context.Canceled", err)
+	}
+}
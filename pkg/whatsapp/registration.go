@@ -0,0 +1,73 @@
+package whatsapp
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Registration reports whether a phone number has a WhatsApp account
+type Registration struct {
+	PhoneNumber string
+	JID         types.JID
+	Registered  bool
+	// BusinessName is the verified business name, if the number is a business
+	BusinessName  string
+	BusinessLevel BusinessLevel
+	Err           error
+}
+
+// CheckRegistered looks up which phone numbers are on WhatsApp in a single
+// query. Numbers that fail to parse get their own Err; results are returned in
+// input order.
+func (c *Client) CheckRegistered(phoneNumbers []string) []Registration {
+	results := make([]Registration, len(phoneNumbers))
+	indexByQuery := make(map[string][]int)
+	var queries []string
+
+	for i, phoneNumber := range phoneNumbers {
+		results[i].PhoneNumber = phoneNumber
+		jid, err := c.parsePhoneNumber(phoneNumber)
+		if err != nil {
+			results[i].Err = fmt.Errorf("failed to parse phone number: %w", err)
+			continue
+		}
+		results[i].JID = jid
+
+		// WhatsApp expects international numbers with a leading +
+		query := "+" + jid.User
+		if _, seen := indexByQuery[query]; !seen {
+			queries = append(queries, query)
+		}
+		indexByQuery[query] = append(indexByQuery[query], i)
+	}
+
+	if len(queries) == 0 {
+		return results
+	}
+
+	responses, err := c.client.IsOnWhatsApp(queries)
+	if err != nil {
+		for _, indexes := range indexByQuery {
+			for _, i := range indexes {
+				results[i].Err = fmt.Errorf("failed to check registration: %w", err)
+			}
+		}
+		return results
+	}
+
+	for _, response := range responses {
+		for _, i := range indexByQuery[response.Query] {
+			results[i].Registered = response.IsIn
+			if response.IsIn {
+				results[i].JID = response.JID
+			}
+			if business := BusinessOf(response.VerifiedName); business != nil {
+				results[i].BusinessName = business.Name
+				results[i].BusinessLevel = business.Level
+			}
+		}
+	}
+
+	return results
+}
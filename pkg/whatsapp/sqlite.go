@@ -0,0 +1,50 @@
+package whatsapp
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Default SQLite settings for the session database
+const (
+	DefaultSQLiteBusyTimeout = 5 * time.Second
+	DefaultSQLiteJournalMode = "WAL"
+	DefaultSQLiteSynchronous = "NORMAL"
+)
+
+// SQLiteOptions tunes the session database connection. Zero fields keep the default.
+type SQLiteOptions struct {
+	// BusyTimeout is how long a statement waits for a lock before failing with "database is locked"
+	BusyTimeout time.Duration
+	// JournalMode is the SQLite journal_mode, such as WAL or DELETE
+	JournalMode string
+	// Synchronous is the SQLite synchronous level, such as NORMAL or FULL
+	Synchronous string
+}
+
+// WithSQLiteOptions sets the pragmas used when opening the session database
+func WithSQLiteOptions(opts SQLiteOptions) Option {
+	return func(c *Client) {
+		if opts.BusyTimeout > 0 {
+			c.sqlite.BusyTimeout = opts.BusyTimeout
+		}
+		if opts.JournalMode != "" {
+			c.sqlite.JournalMode = opts.JournalMode
+		}
+		if opts.Synchronous != "" {
+			c.sqlite.Synchronous = opts.Synchronous
+		}
+	}
+}
+
+// dsn builds the go-sqlite3 connection string for dbPath, which applies the
+// pragmas to every connection in the pool
+func (o SQLiteOptions) dsn(dbPath string) string {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", strconv.FormatInt(o.BusyTimeout.Milliseconds(), 10))
+	params.Set("_journal_mode", o.JournalMode)
+	params.Set("_synchronous", o.Synchronous)
+	return sqliteURI(dbPath) + "?" + params.Encode()
+}
@@ -0,0 +1,35 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDSNEscapesDatabasePath(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "odd?name#1%.db")
+	opts := SQLiteOptions{
+		BusyTimeout: DefaultSQLiteBusyTimeout,
+		JournalMode: "WAL",
+		Synchronous: DefaultSQLiteSynchronous,
+	}
+
+	db, err := sql.Open("sqlite3", opts.dsn(dbPath))
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatalf("PRAGMA journal_mode: %v", err)
+	}
+	if !strings.EqualFold(journalMode, "wal") {
+		t.Errorf("journal_mode = %q, want wal; the pragmas were cut off", journalMode)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("database not created at %s: %v", dbPath, err)
+	}
+}
@@ -0,0 +1,300 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ConnectionState describes where the client is in its connection lifecycle
+type ConnectionState int
+
+const (
+	// StateDisconnected means there is no connection to WhatsApp
+	StateDisconnected ConnectionState = iota
+	// StateConnecting means a connection attempt is in progress
+	StateConnecting
+	// StateConnected means the websocket is connected and authenticated
+	StateConnected
+	// StateLoggingIn means a QR or phone pairing is in progress
+	StateLoggingIn
+	// StateLoggedOut means WhatsApp ended the session; it stays until the
+	// device is paired again
+	StateLoggedOut
+)
+
+// String returns a human readable name for the state
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateLoggingIn:
+		return "logging_in"
+	case StateLoggedOut:
+		return "logged_out"
+	default:
+		return "unknown"
+	}
+}
+
+// Status is a snapshot of the client's connection state
+type Status struct {
+	State    ConnectionState
+	LoggedIn bool
+
+	// AppStateEvents counts app state mutations (contact names, settings) received this session
+	AppStateEvents int
+	// AppStateSynced lists the app state patches fully synced this session
+	AppStateSynced []string
+
+	// LastPresenceSent is when available presence was last sent, zero if never
+	LastPresenceSent time.Time
+}
+
+// Status returns the current connection status
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	status := Status{
+		State:            c.state,
+		AppStateEvents:   c.appStateEvents,
+		LastPresenceSent: c.lastPresenceSent,
+	}
+	for name := range c.appStateSynced {
+		status.AppStateSynced = append(status.AppStateSynced, string(name))
+	}
+	c.mu.Unlock()
+
+	sort.Strings(status.AppStateSynced)
+	status.LoggedIn = c.IsLoggedIn()
+	return status
+}
+
+// WaitForAppStateSync blocks until contact names are available: either the
+// contacts app state patch finished syncing this session, or the contact store
+// already holds contacts from an earlier sync
+func (c *Client) WaitForAppStateSync(ctx context.Context) error {
+	contacts, err := c.client.DeviceStore().Contacts.GetAllContacts(ctx)
+	if err == nil && len(contacts) > 0 {
+		return nil
+	}
+
+	for {
+		c.mu.Lock()
+		synced := c.appStateSynced[appstate.WAPatchCriticalUnblockLow]
+		changed := c.stateChanged
+		c.mu.Unlock()
+
+		if synced {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for app state sync: %w", ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// OnLoggedOut registers a callback invoked when WhatsApp logs the session out,
+// e.g. because the device was unlinked from the phone. whatsmeow has already
+// removed the device from the store by then, so the session must be paired again.
+// Callbacks run synchronously on the event goroutine, so they should return quickly.
+func (c *Client) OnLoggedOut(fn func(reason string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.loggedOutHandlers = append(c.loggedOutHandlers, fn)
+}
+
+// handleLoggedOut marks the client logged out and notifies the logout callbacks
+func (c *Client) handleLoggedOut(evt *events.LoggedOut) {
+	reason := "device was removed"
+	if evt.OnConnect {
+		reason = evt.Reason.String()
+	}
+	log.Printf("Logged out by WhatsApp: %s", reason)
+
+	c.mu.Lock()
+	clear(c.online)
+	handlers := append([]func(string){}, c.loggedOutHandlers...)
+	c.mu.Unlock()
+
+	c.setState(StateLoggedOut)
+	for _, handler := range handlers {
+		handler(reason)
+	}
+}
+
+// WithEventHandler passes every raw whatsmeow event to fn after the client has
+// handled it, including those emitted while connecting. fn runs synchronously
+// on the event goroutine, so it should return quickly.
+func WithEventHandler(fn func(evt any)) Option {
+	return func(c *Client) {
+		c.eventListeners = append(c.eventListeners, fn)
+	}
+}
+
+// OnStateChange registers a callback invoked whenever the connection state changes.
+// Callbacks run synchronously on the event goroutine, so they should return quickly.
+func (c *Client) OnStateChange(fn func(state ConnectionState)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stateHandlers = append(c.stateHandlers, fn)
+}
+
+// State returns the current connection state
+func (c *Client) State() ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.state
+}
+
+// requireConnected fails unless the session is connected and authenticated
+func (c *Client) requireConnected() error {
+	if state := c.State(); state != StateConnected {
+		return fmt.Errorf("not connected to WhatsApp (%s)", state)
+	}
+	return nil
+}
+
+// WaitForState blocks until the client reaches state or ctx is done
+func (c *Client) WaitForState(ctx context.Context, state ConnectionState) error {
+	for {
+		c.mu.Lock()
+		current := c.state
+		changed := c.stateChanged
+		c.mu.Unlock()
+
+		if current == state {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s state (currently %s): %w", state, current, ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// WaitForPairing blocks until pairing succeeds, fails or ctx is done
+func (c *Client) WaitForPairing(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		paired := c.paired
+		pairErr := c.pairErr
+		changed := c.stateChanged
+		c.mu.Unlock()
+
+		if paired || c.client.DeviceStore().ID != nil {
+			log.Println("Successfully paired with WhatsApp")
+			return nil
+		}
+		if pairErr != nil {
+			return fmt.Errorf("pairing failed: %w", pairErr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for pairing: %w", ctx.Err())
+		case <-changed:
+		}
+	}
+}
+
+// setState records a new connection state and notifies registered callbacks
+func (c *Client) setState(state ConnectionState) {
+	c.mu.Lock()
+	if c.state == state {
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	handlers := append([]func(ConnectionState){}, c.stateHandlers...)
+
+	c.notifyLocked()
+	c.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(state)
+	}
+}
+
+// notifyLocked wakes up everyone waiting on a status change; the caller must hold c.mu
+func (c *Client) notifyLocked() {
+	close(c.stateChanged)
+	c.stateChanged = make(chan struct{})
+}
+
+// handleEvent translates whatsmeow events into connection state changes,
+// presence notifications and profile cache invalidation
+func (c *Client) handleEvent(evt interface{}) {
+	switch e := evt.(type) {
+	case *events.Connected:
+		c.setState(StateConnected)
+		// Presence subscriptions don't survive a reconnect
+		go c.resubscribePresence()
+	case *events.LoggedOut:
+		c.handleLoggedOut(e)
+	case *events.Disconnected, *events.StreamReplaced:
+		// Presence updates were missed while offline, so treat everyone as offline again
+		c.mu.Lock()
+		clear(c.online)
+		c.offlineSynced = false
+		loggedOut := c.state == StateLoggedOut
+		c.mu.Unlock()
+		// The connection closing after a logout doesn't undo the logout
+		if !loggedOut {
+			c.setState(StateDisconnected)
+		}
+	case *events.Presence:
+		c.handlePresence(e)
+	case *events.Picture:
+		// The cached picture is stale once the contact changes or removes it
+		if c.cache != nil {
+			c.cache.remove(e.JID)
+		}
+	case *events.PairSuccess:
+		c.mu.Lock()
+		c.paired = true
+		c.notifyLocked()
+		c.mu.Unlock()
+		c.setState(StateLoggingIn)
+	case *events.PairError:
+		c.mu.Lock()
+		c.pairErr = e.Error
+		c.notifyLocked()
+		c.mu.Unlock()
+	case *events.AppState:
+		c.mu.Lock()
+		c.appStateEvents++
+		c.mu.Unlock()
+	case *events.OfflineSyncCompleted:
+		c.mu.Lock()
+		c.offlineSynced = true
+		c.notifyLocked()
+		c.mu.Unlock()
+	case *events.AppStateSyncComplete:
+		log.Printf("App state %s synced", e.Name)
+		c.mu.Lock()
+		c.appStateSynced[e.Name] = true
+		c.notifyLocked()
+		c.mu.Unlock()
+	}
+
+	for _, listener := range c.eventListeners {
+		listener(evt)
+	}
+}
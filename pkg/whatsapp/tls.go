@@ -0,0 +1,51 @@
+package whatsapp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+
+	"go-web-wa/pkg/netproxy"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/net/proxy"
+)
+
+// WithRootCAs verifies the WhatsApp websocket and image downloads against
+// rootCAs instead of the system roots, e.g. to trust a TLS-inspecting proxy
+func WithRootCAs(rootCAs *x509.CertPool) Option {
+	return func(c *Client) {
+		c.rootCAs = rootCAs
+	}
+}
+
+// websocketDialer builds the websocket dialer for a custom root pool. whatsmeow
+// ignores its own proxy settings once a dialer is set, so the proxy is applied
+// here the same way SetProxyAddress would.
+func websocketDialer(proxyURL string, rootCAs *x509.CertPool) (*websocket.Dialer, error) {
+	dialer := &websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: rootCAs},
+	}
+
+	parsed, err := netproxy.Parse(proxyURL)
+	if err != nil || parsed == nil {
+		return dialer, err
+	}
+	if parsed.Scheme != "socks5" {
+		dialer.Proxy = http.ProxyURL(parsed)
+		return dialer, nil
+	}
+
+	socksDialer, err := proxy.FromURL(parsed, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+	}
+	dialer.Proxy = nil
+	dialer.NetDial = socksDialer.Dial
+	if contextDialer, ok := socksDialer.(proxy.ContextDialer); ok {
+		dialer.NetDialContext = contextDialer.DialContext
+	}
+	return dialer, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+// placeholderImage returns the image posted for numbers without a profile
+// picture: the file at PLACEHOLDER_IMAGE_PATH, or a built-in silhouette
+func placeholderImage(path string) ([]byte, string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read placeholder image: %w", err)
+		}
+		return data, path, nil
+	}
+
+	data, err := defaultPlaceholder()
+	return data, "placeholder.png", err
+}
+
+// defaultPlaceholder renders a grey head-and-shoulders silhouette like the one
+// WhatsApp shows for contacts without a picture
+func defaultPlaceholder() ([]byte, error) {
+	const size = 256
+	background := color.RGBA{0xDF, 0xE5, 0xE7, 0xFF}
+	silhouette := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, background)
+
+			// Head: circle centred in the upper half
+			dx, dy := x-size/2, y-size*2/5
+			if dx*dx+dy*dy <= (size/6)*(size/6) {
+				img.Set(x, y, silhouette)
+			}
+
+			// Shoulders: the top of a wide ellipse cut off by the bottom edge
+			dx, dy = x-size/2, y-size
+			if dx*dx*4+dy*dy*9 <= (size*3/4)*(size*3/4) {
+				img.Set(x, y, silhouette)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode placeholder image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"go-web-wa/pkg/batch"
+	"go-web-wa/pkg/mask"
+)
+
+// clearLine returns the cursor to the start of the line and erases it
+const clearLine = "\r\033[K"
+
+// progressWriter is stderr shared by log records and the progress line. While
+// a progress line is shown, every log record clears it first and redraws it
+// after, so records never run into it. slog writes each record in one call.
+type progressWriter struct {
+	w io.Writer
+
+	mu   sync.Mutex
+	line string
+}
+
+// Write writes a log record above the progress line
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.line == "" {
+		return p.w.Write(b)
+	}
+	io.WriteString(p.w, clearLine)
+	n, err := p.w.Write(b)
+	io.WriteString(p.w, p.line)
+	return n, err
+}
+
+// reporter returns a batch.ProgressFunc that redraws the progress line,
+// masking numbers when MASK_PHONE_NUMBERS is set, and ends the line once
+// every target is done
+func (p *progressWriter) reporter(masker *mask.Masker) batch.ProgressFunc {
+	return func(progress batch.Progress) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.line = fmt.Sprintf("[%3d%%] %d/%d %s  ✓ %d  ✗ %d", progress.Percent(), progress.Done, progress.Total, masker.Text(progress.Number), progress.Succeeded, progress.Failed)
+		io.WriteString(p.w, clearLine+p.line)
+		if progress.Done == progress.Total {
+			io.WriteString(p.w, "\n")
+			p.line = ""
+		}
+	}
+}
+
+// isTerminal reports whether file is an interactive terminal rather than a
+// pipe, file or /dev/null
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
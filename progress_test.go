@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go-web-wa/pkg/batch"
+)
+
+func TestProgressWriterKeepsLogsOffTheProgressLine(t *testing.T) {
+	var out bytes.Buffer
+	stderr := &progressWriter{w: &out}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	report := stderr.reporter(nil)
+
+	report(batch.Progress{Number: "+1111111111", Done: 1, Total: 2, Succeeded: 1})
+	logger.Info("fetching second target")
+	report(batch.Progress{Number: "+2222222222", Done: 2, Total: 2, Succeeded: 1, Failed: 1})
+	logger.Info("done")
+
+	// What a terminal shows: each \r starts the line over and \033[K erases it
+	var screen []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		parts := strings.Split(line, "\r")
+		screen = append(screen, strings.TrimPrefix(parts[len(parts)-1], "\033[K"))
+	}
+
+	want := []string{
+		`msg="fetching second target"`,
+		"[100%] 2/2 +2222222222  ✓ 1  ✗ 1",
+		`msg=done`,
+	}
+	if len(screen) != 4 || screen[3] != "" {
+		t.Fatalf("screen = %q, want three lines ending in a newline", screen)
+	}
+	for i, w := range want {
+		if !strings.Contains(screen[i], w) || (i != 1 && strings.Contains(screen[i], "%]")) {
+			t.Errorf("line %d = %q, want %q on its own", i, screen[i], w)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go-web-wa/pkg/batch"
+)
+
+// runReport is the audit record written to REPORT_DIR after every run
+type runReport struct {
+	Started    time.Time      `json:"started"`
+	Finished   time.Time      `json:"finished"`
+	DurationMS int64          `json:"duration_ms"`
+	Targets    []string       `json:"targets"`
+	Changed    int            `json:"changed"`
+	Failed     int            `json:"failed"`
+	TotalBytes int            `json:"total_bytes"`
+	Results    []reportResult `json:"results"`
+	Errors     []reportError  `json:"errors,omitempty"`
+}
+
+// reportResult is the outcome of one target in a run report
+type reportResult struct {
+	Number        string `json:"number"`
+	Name          string `json:"name,omitempty"`
+	Bytes         int    `json:"bytes"`
+	Changed       bool   `json:"changed"`
+	Unchanged     bool   `json:"unchanged,omitempty"`
+	Skipped       bool   `json:"skipped,omitempty"`
+	Suppressed    bool   `json:"suppressed,omitempty"`
+	Removed       bool   `json:"removed,omitempty"`
+	ImageURL      string `json:"image_url,omitempty"`
+	Error         string `json:"error,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// reportError is a failure shared by one or more targets
+type reportError struct {
+	Message string   `json:"message"`
+	Numbers []string `json:"numbers"`
+}
+
+// writeReport saves the run as report_<timestamp>.json in REPORT_DIR and
+// removes the oldest reports beyond REPORT_KEEP. Failures are logged only.
+func (f *fetcher) writeReport(result batch.FetchResult) {
+	if f.cfg.ReportDir == "" {
+		return
+	}
+
+	report := runReport{
+		Started:    result.Started,
+		Finished:   result.Started.Add(result.Duration),
+		DurationMS: result.Duration.Milliseconds(),
+		Targets:    make([]string, len(result.Items)),
+		Changed:    result.ChangedCount(),
+		Failed:     len(result.Failed()),
+		TotalBytes: result.TotalBytes(),
+		Results:    make([]reportResult, len(result.Items)),
+	}
+	for i, item := range result.Items {
+		report.Targets[i] = item.Number
+		report.Results[i] = reportResult{
+			Number:        item.Number,
+			Name:          item.Name,
+			Bytes:         item.Bytes,
+			Changed:       item.Changed,
+			Unchanged:     item.Unchanged,
+			Skipped:       item.Skipped,
+			Suppressed:    item.Suppressed,
+			Removed:       item.Removed,
+			ImageURL:      item.ImageURL,
+			CorrelationID: item.CorrelationID,
+		}
+		if item.Err != nil {
+			report.Results[i].Error = item.Err.Error()
+		}
+	}
+	for _, group := range result.ErrorGroups() {
+		report.Errors = append(report.Errors, reportError{Message: group.Message, Numbers: group.Numbers})
+	}
+
+	path, err := saveReport(f.cfg.ReportDir, report)
+	if err != nil {
+		log.Printf("Failed to write run report: %v", err)
+		return
+	}
+	log.Printf("Wrote run report to %s", path)
+
+	if err := pruneReports(f.cfg.ReportDir, f.cfg.ReportKeep); err != nil {
+		log.Printf("Failed to remove old run reports: %v", err)
+	}
+}
+
+// saveReport writes report atomically into dir and returns its path
+func saveReport(dir string, report runReport) (string, error) {
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	// Milliseconds keep back-to-back watch cycles apart and names sortable
+	path := filepath.Join(dir, fmt.Sprintf("report_%s.json", report.Started.Format("20060102_150405.000")))
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, raw, 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return "", fmt.Errorf("failed to replace report: %w", err)
+	}
+	return path, nil
+}
+
+// pruneReports removes all but the newest keep reports in dir; keep 0 keeps all
+func pruneReports(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var reports []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, "report_") && strings.HasSuffix(name, ".json") {
+			reports = append(reports, name)
+		}
+	}
+	if len(reports) <= keep {
+		return nil
+	}
+
+	// Timestamped names sort oldest first
+	sort.Strings(reports)
+	for _, name := range reports[:len(reports)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
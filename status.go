@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"go-web-wa/pkg/correlation"
+	"go-web-wa/pkg/state"
+	"go-web-wa/pkg/whatsapp"
+)
+
+// checkStatus fetches a number's "about" text and posts the old and new text
+// when it differs from the stored one. The first check only records a baseline.
+func (f *fetcher) checkStatus(ctx context.Context, phoneNumber string) {
+	// Channels have a description instead of an "about" text
+	if whatsapp.IsNewsletter(phoneNumber) {
+		return
+	}
+	ctx = correlation.WithFields(ctx, "number", phoneNumber, "operation", "status")
+	status, err := f.wa.GetStatusMessage(phoneNumber)
+	if err != nil {
+		correlation.Logf(ctx, "Failed to fetch status for %s: %v", phoneNumber, err)
+		return
+	}
+	status = strings.TrimSpace(status)
+
+	previous := f.state.Number(phoneNumber)
+	firstCheck := previous.StatusCheckedAt.IsZero()
+	changed := !firstCheck && status != previous.Status
+
+	// Only remember a new status once it was posted, so a failed post is retried
+	if changed {
+		correlation.Logf(ctx, "Status for %s changed", phoneNumber)
+		name, err := f.wa.ContactName(phoneNumber)
+		if err != nil {
+			correlation.Logf(ctx, "Failed to look up contact name for %s: %v", phoneNumber, err)
+		}
+		if err := f.discordFor(phoneNumber).SendStatusChange(phoneNumber, name, previous.Status, status); err != nil {
+			correlation.Logf(ctx, "Failed to send status change to Discord: %v", err)
+			return
+		}
+	}
+
+	if err := f.state.UpdateNumber(phoneNumber, func(ns *state.NumberState) {
+		now := f.clock.Now()
+		ns.Status = status
+		ns.StatusCheckedAt = now
+		if changed {
+			ns.StatusChangedAt = now
+		}
+	}); err != nil {
+		correlation.Logf(ctx, "Failed to record status for %s: %v", phoneNumber, err)
+	}
+
+	if firstCheck {
+		correlation.Logf(ctx, "Recorded initial status for %s", phoneNumber)
+	}
+}
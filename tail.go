@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"go.mau.fi/whatsmeow/types/events"
+
+	"go-web-wa/pkg/whatsapp"
+)
+
+// tailEvents connects and logs every WhatsApp event until interrupted
+func tailEvents() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	waClient, code := connectSession(ctx, whatsapp.WithEventHandler(logEvent))
+	if waClient == nil {
+		return code
+	}
+	defer waClient.Close()
+
+	log.Printf("Tailing WhatsApp events, press Ctrl+C to stop")
+	<-ctx.Done()
+	return exitSuccess
+}
+
+// logEvent logs one whatsmeow event with the fields useful for debugging.
+// Message contents are left out; only their kind is shown.
+func logEvent(evt any) {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", evt), "*events.")
+
+	var attrs []any
+	switch e := evt.(type) {
+	case *events.Message:
+		attrs = []any{"id", e.Info.ID, "chat", e.Info.Chat, "sender", e.Info.Sender, "type", e.Info.Type, "media_type", e.Info.MediaType, "from_me", e.Info.IsFromMe}
+	case *events.Receipt:
+		attrs = []any{"chat", e.Chat, "sender", e.Sender, "type", e.Type, "messages", len(e.MessageIDs)}
+	case *events.Presence:
+		attrs = []any{"from", e.From, "unavailable", e.Unavailable, "last_seen", e.LastSeen}
+	case *events.ChatPresence:
+		attrs = []any{"chat", e.Chat, "sender", e.Sender, "state", e.State, "media", e.Media}
+	case *events.Picture:
+		attrs = []any{"jid", e.JID, "author", e.Author, "removed", e.Remove, "picture_id", e.PictureID}
+	case *events.UserAbout:
+		attrs = []any{"jid", e.JID}
+	case *events.PushName:
+		attrs = []any{"jid", e.JID, "old", e.OldPushName, "new", e.NewPushName}
+	case *events.Disconnected, *events.Connected, *events.KeepAliveTimeout, *events.KeepAliveRestored:
+	case *events.LoggedOut:
+		attrs = []any{"on_connect", e.OnConnect, "reason", e.Reason.String()}
+	case *events.AppStateSyncComplete:
+		attrs = []any{"name", e.Name}
+	case *events.OfflineSyncCompleted:
+		attrs = []any{"count", e.Count}
+	}
+
+	slog.Info("WhatsApp event", append([]any{"event", name}, attrs...)...)
+}
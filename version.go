@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.buildDate=2025-01-01T00:00:00Z"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildCommit returns the commit set at build time, falling back to the VCS
+// revision Go embeds when building from a checkout, marked dirty with local changes
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// buildVersion describes the running binary in one short string, e.g. "v1.2.3 (abc1234)"
+func buildVersion() string {
+	if c := buildCommit(); c != "" {
+		return fmt.Sprintf("%s (%s)", version, c)
+	}
+	return version
+}
+
+// printVersion prints the version, commit and build date
+func printVersion() int {
+	fmt.Printf("version:    %s\n", version)
+	if c := buildCommit(); c != "" {
+		fmt.Printf("commit:     %s\n", c)
+	}
+	if buildDate != "" {
+		fmt.Printf("build date: %s\n", buildDate)
+	}
+	return exitSuccess
+}